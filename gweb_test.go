@@ -1,12 +1,34 @@
 package gweb
 
 import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
 	"encoding/json"
+	"encoding/pem"
+	"encoding/xml"
+	"errors"
+	"fmt"
 	"html/template"
+	"io"
+	"log/slog"
+	"math/big"
+	"mime/multipart"
+	"net"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
 	"strings"
 	"testing"
+	"time"
 )
 
 //write tests
@@ -236,7 +258,10 @@ func TestGroupMiddleware(t *testing.T) {
 
 	web := New()
 	//web.Use(MiddlewareJwt("secret"))
-	v1 := web.Group("/v1")
+	v1, err := web.Group("/v1")
+	if err != nil {
+		t.Fatal(err)
+	}
 
 	v1.Get("/world", func(ctx *WebContext) error {
 
@@ -290,3 +315,1946 @@ func TestRenderFiles(t *testing.T) {
 			status, http.StatusOK)
 	}
 }
+
+// go test -v -run TestNewWebIsolated
+func TestNewWebIsolated(t *testing.T) {
+
+	public, err := NewWeb(WithLogging())
+	if err != nil {
+		t.Fatal(err)
+	}
+	admin, err := NewWeb()
+	if err != nil {
+		t.Fatal(err)
+	}
+	public.Get("/status", func(ctx *WebContext) error {
+
+		ctx.Status(200).SendString(strings.NewReader("public"))
+		return nil
+	})
+	//registering the same pattern on another instance must not panic
+	admin.Get("/status", func(ctx *WebContext) error {
+
+		ctx.Status(200).SendString(strings.NewReader("admin"))
+		return nil
+	})
+	if !public.logging || admin.logging {
+		t.Errorf("logging setting leaked between instances")
+	}
+
+	for web, expected := range map[*Web]string{public: "public", admin: "admin"} {
+		req, err := http.NewRequest("GET", "/status", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rr := httptest.NewRecorder()
+		web.WebTest(rr, req)
+		if rr.Body.String() != expected {
+			t.Errorf("handler returned unexpected body: got %v want %v",
+				rr.Body.String(), expected)
+		}
+	}
+}
+
+// go test -v -run TestNewWebInvalidOption
+func TestNewWebInvalidOption(t *testing.T) {
+
+	if _, err := NewWeb(WithLogger(nil)); err == nil {
+		t.Errorf("expected an error for a nil logger")
+	}
+}
+
+// go test -v -run TestShutdown
+func TestShutdown(t *testing.T) {
+
+	web := New()
+	web.Get("/slow", func(ctx *WebContext) error {
+
+		time.Sleep(100 * time.Millisecond)
+		ctx.Status(200).SendString(strings.NewReader("done"))
+		return nil
+	})
+	ready := make(chan struct{})
+	var order []string
+	web.OnStart(func(ctx context.Context) error {
+		close(ready)
+		return nil
+	})
+	web.OnShutdown(func(ctx context.Context) error {
+		order = append(order, "db")
+		return nil
+	})
+	web.OnShutdown(func(ctx context.Context) error {
+		order = append(order, "consumer")
+		return nil
+	})
+
+	runErr := make(chan error, 1)
+	go func() {
+		runErr <- web.Run("127.0.0.1:0")
+	}()
+	select {
+	case <-ready:
+	case err := <-runErr:
+		t.Fatal(err)
+	}
+
+	body := make(chan string, 1)
+	go func() {
+		res, err := http.Get("http://" + web.Addr().String() + "/slow")
+		if err != nil {
+			body <- err.Error()
+			return
+		}
+		defer res.Body.Close()
+		b, _ := io.ReadAll(res.Body)
+		body <- string(b)
+	}()
+	//let the request reach the handler before shutting down
+	time.Sleep(20 * time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := web.Shutdown(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if got := <-body; got != "done" {
+		t.Errorf("in flight request was not drained: got %v want %v", got, "done")
+	}
+	if err := <-runErr; !errors.Is(err, http.ErrServerClosed) {
+		t.Errorf("Run returned %v want %v", err, http.ErrServerClosed)
+	}
+	if strings.Join(order, ",") != "consumer,db" {
+		t.Errorf("shutdown hooks called in wrong order: %v", order)
+	}
+}
+
+// testCert ... creates a certificate signed by parent, self signed if parent is nil
+func testCert(t *testing.T, template *x509.Certificate, parent *x509.Certificate, parentKey *ecdsa.PrivateKey) (*x509.Certificate, *ecdsa.PrivateKey) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template.NotBefore = time.Now().Add(-time.Hour)
+	template.NotAfter = time.Now().Add(time.Hour)
+	if parent == nil {
+		parent, parentKey = template, key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, parentKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert, key
+}
+
+// writeTestCert ... writes the certificate and key as PEM files
+func writeTestCert(t *testing.T, cert *x509.Certificate, key *ecdsa.PrivateKey, certFile string, keyFile string) {
+	t.Helper()
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certPem := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw})
+	keyPem := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer})
+	if err := os.WriteFile(certFile, certPem, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyFile, keyPem, 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// go test -v -run TestRunTLS
+func TestRunTLS(t *testing.T) {
+
+	ca, caKey := testCert(t, &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "gweb test ca"},
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}, nil, nil)
+	serverTemplate := func(serial int64) *x509.Certificate {
+		return &x509.Certificate{
+			SerialNumber: big.NewInt(serial),
+			Subject:      pkix.Name{CommonName: "localhost"},
+			IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+			ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		}
+	}
+	dir := t.TempDir()
+	certFile := filepath.Join(dir, "server.pem")
+	keyFile := filepath.Join(dir, "server.key")
+	serverCert, serverKey := testCert(t, serverTemplate(2), ca, caKey)
+	writeTestCert(t, serverCert, serverKey, certFile, keyFile)
+
+	clientCert, clientKey := testCert(t, &x509.Certificate{
+		SerialNumber: big.NewInt(3),
+		Subject:      pkix.Name{CommonName: "client-1"},
+		DNSNames:     []string{"client.example.com"},
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}, ca, caKey)
+
+	pool := x509.NewCertPool()
+	pool.AddCert(ca)
+	web, err := NewWeb(WithClientAuth(pool, tls.RequireAndVerifyClientCert))
+	if err != nil {
+		t.Fatal(err)
+	}
+	web.Get("/whoami", func(ctx *WebContext) error {
+
+		identity := ctx.PeerIdentity()
+		if identity == nil {
+			ctx.Status(401)
+			return nil
+		}
+		ctx.Status(200).SendString(strings.NewReader(identity.CommonName + " " + strings.Join(identity.DNSNames, ",")))
+		return nil
+	})
+	ready := make(chan struct{})
+	web.OnStart(func(ctx context.Context) error {
+		close(ready)
+		return nil
+	})
+	runErr := make(chan error, 1)
+	go func() {
+		runErr <- web.RunTLS("127.0.0.1:0", certFile, keyFile)
+	}()
+	select {
+	case <-ready:
+	case err := <-runErr:
+		t.Fatal(err)
+	}
+	defer web.Shutdown(context.Background())
+
+	client := &http.Client{Transport: &http.Transport{
+		DisableKeepAlives: true,
+		TLSClientConfig: &tls.Config{
+			RootCAs: pool,
+			Certificates: []tls.Certificate{{
+				Certificate: [][]byte{clientCert.Raw},
+				PrivateKey:  clientKey,
+			}},
+		},
+	}}
+	get := func() (string, *big.Int) {
+		t.Helper()
+		res, err := client.Get("https://" + web.Addr().String() + "/whoami")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer res.Body.Close()
+		b, _ := io.ReadAll(res.Body)
+		return string(b), res.TLS.PeerCertificates[0].SerialNumber
+	}
+
+	body, serial := get()
+	if body != "client-1 client.example.com" {
+		t.Errorf("handler returned unexpected identity: got %v", body)
+	}
+	if serial.Int64() != 2 {
+		t.Errorf("server presented serial %v want 2", serial)
+	}
+
+	//replace the certificate on disk, the next handshake must pick it up
+	serverCert, serverKey = testCert(t, serverTemplate(4), ca, caKey)
+	writeTestCert(t, serverCert, serverKey, certFile, keyFile)
+	later := time.Now().Add(time.Minute)
+	os.Chtimes(certFile, later, later)
+	os.Chtimes(keyFile, later, later)
+	//the files are checked at most once every certCheckInterval
+	time.Sleep(certCheckInterval)
+
+	if _, serial = get(); serial.Int64() != 4 {
+		t.Errorf("certificate was not reloaded: got serial %v want 4", serial)
+	}
+
+	//a broken pair keeps the last good certificate and is logged once until the files change again
+	var logs bytes.Buffer
+	reloader, err := newCertReloader(certFile, keyFile, slog.New(slog.NewTextHandler(&logs, nil)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	os.WriteFile(certFile, []byte("-----BEGIN CERTIFICATE-----\ntruncated"), 0600)
+	for i := 1; i <= 2; i++ {
+		changed := later.Add(time.Duration(i) * time.Minute)
+		os.Chtimes(certFile, changed, changed)
+		for range 3 {
+			if reloader.reload() == nil {
+				t.Errorf("expected an error for a truncated certificate")
+			}
+		}
+		if n := strings.Count(logs.String(), "reloading the TLS certificate failed"); n != i {
+			t.Errorf("reload failure logged %v times want %v: %v", n, i, logs.String())
+		}
+	}
+	if cert, _ := reloader.getCertificate(nil); cert == nil || cert.Leaf == nil || cert.Leaf.SerialNumber.Int64() != 4 {
+		t.Errorf("expected the last good certificate to be served")
+	}
+}
+
+// go test -v -run TestServerOptions
+func TestServerOptions(t *testing.T) {
+
+	web, err := NewWeb(
+		WithReadTimeout(5*time.Second),
+		WithWriteTimeout(0),
+		WithIdleTimeout(time.Minute),
+		WithReadHeaderTimeout(time.Second),
+		WithMaxHeaderBytes(4096),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if web.httpServer.ReadTimeout != 5*time.Second || web.httpServer.WriteTimeout != 0 ||
+		web.httpServer.IdleTimeout != time.Minute || web.httpServer.ReadHeaderTimeout != time.Second ||
+		web.httpServer.MaxHeaderBytes != 4096 {
+		t.Errorf("server settings not applied: %+v", web.httpServer)
+	}
+	if web.httpServer.ErrorLog == nil {
+		t.Errorf("server error log not routed to WebLog")
+	}
+
+	invalid := map[string]WebOption{
+		"read timeout":     WithReadTimeout(-time.Second),
+		"write timeout":    WithWriteTimeout(-time.Second),
+		"max header bytes": WithMaxHeaderBytes(0),
+		"base context":     WithBaseContext(nil),
+		"conn context":     WithConnContext(nil),
+	}
+	for name, opt := range invalid {
+		if _, err := NewWeb(opt); err == nil {
+			t.Errorf("expected an error for an invalid %s", name)
+		}
+	}
+}
+
+// go test -v -run TestRunUnix
+func TestRunUnix(t *testing.T) {
+
+	path := filepath.Join(t.TempDir(), "gweb.sock")
+	//leave a stale socket file behind like a crashed process would
+	stale, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stale.(*net.UnixListener).SetUnlinkOnClose(false)
+	stale.Close()
+
+	web, err := NewWeb(WithSocketMode(0600))
+	if err != nil {
+		t.Fatal(err)
+	}
+	web.Get("/world", func(ctx *WebContext) error {
+
+		ctx.Status(200).SendString(strings.NewReader("Hello, world!"))
+		return nil
+	})
+	ready := make(chan struct{})
+	web.OnStart(func(ctx context.Context) error {
+		close(ready)
+		return nil
+	})
+	runErr := make(chan error, 1)
+	go func() {
+		runErr <- web.RunUnix(path)
+	}()
+	select {
+	case <-ready:
+	case err := <-runErr:
+		t.Fatal(err)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("socket has wrong permissions: got %v want %v", info.Mode().Perm(), os.FileMode(0600))
+	}
+	client := &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, "unix", path)
+		},
+	}}
+	res, err := client.Get("http://gweb/world")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	b, _ := io.ReadAll(res.Body)
+	if string(b) != "Hello, world!" {
+		t.Errorf("handler returned unexpected body: got %v want %v", string(b), "Hello, world!")
+	}
+
+	//a socket with a live server must not be removed
+	if err := New().RunUnix(path); err == nil {
+		t.Errorf("expected an error for a socket in use")
+	}
+
+	//the socket is removed on shutdown and the private directory it was created in is gone
+	web.Shutdown(context.Background())
+	<-runErr
+	entries, err := os.ReadDir(filepath.Dir(path))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 0 {
+		t.Errorf("expected no files left after shutdown got %v", entries)
+	}
+}
+
+// go test -v -run TestSystemdListeners
+func TestSystemdListeners(t *testing.T) {
+
+	//the variables are meant for another process
+	t.Setenv("LISTEN_PID", strconv.Itoa(os.Getpid()+1))
+	t.Setenv("LISTEN_FDS", "1")
+	lns, err := SystemdListeners()
+	if err != nil || lns != nil {
+		t.Errorf("expected no listeners got %v %v", lns, err)
+	}
+	if err := New().RunSystemd(); err == nil {
+		t.Errorf("expected an error without inherited listeners")
+	}
+
+	//pass a listener on fd 3 like systemd does, in a child process so the descriptors of the test are left alone
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	f, err := ln.(*net.TCPListener).File()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	cmd := exec.Command(os.Args[0], "-test.run=^TestInheritedListener$", "-test.v")
+	cmd.Env = append(os.Environ(), "GWEB_SYSTEMD_CHILD=1")
+	cmd.ExtraFiles = []*os.File{f}
+	out, err := cmd.CombinedOutput()
+	if err != nil || !strings.Contains(string(out), "PASS") {
+		t.Errorf("inherited listener failed: %v\n%s", err, out)
+	}
+}
+
+// go test -v -run TestInheritedListener
+// run by TestSystemdListeners in a child process that has the listener on fd 3
+func TestInheritedListener(t *testing.T) {
+
+	if os.Getenv("GWEB_SYSTEMD_CHILD") != "1" {
+		t.Skip("run by TestSystemdListeners")
+	}
+	os.Setenv("LISTEN_PID", strconv.Itoa(os.Getpid()))
+	os.Setenv("LISTEN_FDS", "1")
+	os.Setenv("LISTEN_FDNAMES", "http")
+	lns, err := SystemdListeners()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(lns) != 1 {
+		t.Fatalf("expected 1 listener got %v", len(lns))
+	}
+	defer lns[0].Close()
+	if os.Getenv("LISTEN_PID") != "" || os.Getenv("LISTEN_FDS") != "" {
+		t.Errorf("expected the systemd variables to be cleared")
+	}
+
+	web := New()
+	web.Get("/world", func(ctx *WebContext) error {
+
+		ctx.Status(200).SendString(strings.NewReader("Hello, world!"))
+		return nil
+	})
+	go web.Serve(lns[0])
+	defer web.Shutdown(context.Background())
+	res, err := http.Get("http://" + lns[0].Addr().String() + "/world")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	b, _ := io.ReadAll(res.Body)
+	if string(b) != "Hello, world!" {
+		t.Errorf("handler returned unexpected body: got %v want %v", string(b), "Hello, world!")
+	}
+}
+
+// failingListener ... a listener that can not accept connections
+type failingListener struct {
+	net.Listener
+}
+
+func (l failingListener) Accept() (net.Conn, error) {
+	return nil, errors.New("accept failed")
+}
+
+// go test -v -run TestServeListeners
+func TestServeListeners(t *testing.T) {
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	failing, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	web := New()
+	done := make(chan error, 1)
+	go func() {
+		done <- web.serve(web.httpServer.Serve, ln, failingListener{failing})
+	}()
+	select {
+	case err := <-done:
+		if err == nil || err.Error() != "accept failed" {
+			t.Errorf("serve returned wrong error: got %v want accept failed", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("serve did not return after a listener failed")
+	}
+	//the listener that did not fail must be closed as well
+	if conn, err := net.DialTimeout("tcp", ln.Addr().String(), time.Second); err == nil {
+		conn.Close()
+		t.Errorf("expected the other listener to be closed")
+	}
+}
+
+// go test -v -run TestHTTPHandler
+func TestHTTPHandler(t *testing.T) {
+
+	web := New().WithDefaultCors()
+	web.UseHandler(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(wr http.ResponseWriter, r *http.Request) {
+			wr.Header().Set("X-Wrapped", "yes")
+			next.ServeHTTP(wr, r)
+		})
+	})
+	web.Get("/world", func(ctx *WebContext) error {
+
+		ctx.Status(200).SendString(strings.NewReader("Hello, world!"))
+		return nil
+	})
+	server := httptest.NewServer(web)
+	defer server.Close()
+
+	res, err := http.Get(server.URL + "/world")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	b, _ := io.ReadAll(res.Body)
+	if string(b) != "Hello, world!" {
+		t.Errorf("handler returned unexpected body: got %v want %v", string(b), "Hello, world!")
+	}
+	if res.Header.Get("X-Wrapped") != "yes" {
+		t.Errorf("http middleware was not applied")
+	}
+	if res.Header.Get("Access-Control-Allow-Origin") != "*" {
+		t.Errorf("cors headers missing")
+	}
+
+	//a group mounted in a plain http.ServeMux
+	admin := New()
+	v1, err := admin.Group("/admin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	v1.Get("/stats", func(ctx *WebContext) error {
+
+		ctx.Status(200).SendString(strings.NewReader("stats"))
+		return nil
+	})
+	mux := http.NewServeMux()
+	mux.Handle("/admin/", v1)
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest("GET", "/admin/stats", nil))
+	if rr.Body.String() != "stats" {
+		t.Errorf("group returned unexpected body: got %v want %v", rr.Body.String(), "stats")
+	}
+}
+
+// go test -v -run TestNestedGroups
+func TestNestedGroups(t *testing.T) {
+
+	web := New()
+	var calls []string
+	web.Use(func(ctx *WebContext) error {
+		calls = append(calls, "global")
+		return ctx.Next()
+	})
+	v1, err := web.Group("/v1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	v1.Use(func(ctx *WebContext) error {
+		calls = append(calls, "v1")
+		return ctx.Next()
+	})
+	//a sibling group must not conflict with v1
+	v2, err := web.Group("/v2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	admin, err := v1.Group("/admin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	admin.WithDefaultCors().Use(func(ctx *WebContext) error {
+		calls = append(calls, "admin")
+		return ctx.Next()
+	})
+	handler := func(ctx *WebContext) error {
+
+		ctx.Status(200).SendString(strings.NewReader(ctx.Request.URL.Path))
+		return nil
+	}
+	v1.Get("/user", handler)
+	v2.Get("/user", handler)
+	admin.Get("/stats", handler)
+
+	for path, expected := range map[string]string{
+		"/v1/user":        "global,v1",
+		"/v2/user":        "global",
+		"/v1/admin/stats": "global,v1,admin",
+	} {
+		calls = nil
+		req, err := http.NewRequest("GET", path, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rr := httptest.NewRecorder()
+		web.WebTest(rr, req)
+		if rr.Body.String() != path {
+			t.Errorf("handler returned unexpected body: got %v want %v", rr.Body.String(), path)
+		}
+		if strings.Join(calls, ",") != expected {
+			t.Errorf("%s: middlewares called %v want %v", path, calls, expected)
+		}
+		hasCors := rr.Header().Get("Access-Control-Allow-Origin") != ""
+		if hasCors != (path == "/v1/admin/stats") {
+			t.Errorf("%s: cors setting not inherited correctly", path)
+		}
+	}
+
+	if _, err := web.Group("v3"); err == nil {
+		t.Errorf("expected an error for a prefix without /")
+	}
+	if err := v2.Get("/user", handler); err == nil {
+		t.Errorf("expected an error for a duplicate route")
+	}
+}
+
+// go test -v -run TestMiddlewareChain
+func TestMiddlewareChain(t *testing.T) {
+
+	web := New()
+	var calls []string
+	web.Use(func(ctx *WebContext) error {
+		calls = append(calls, "global before")
+		err := ctx.Next()
+		calls = append(calls, "global after "+strconv.Itoa(ctx.ReplyStatus))
+		return err
+	})
+	v1, err := web.Group("/v1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	v1.Use(func(ctx *WebContext) error {
+		calls = append(calls, "group before")
+		if err := ctx.Next(); err != nil {
+			//recover the error from the handler
+			calls = append(calls, "group recovered")
+			ctx.Status(200).SendString(strings.NewReader("recovered"))
+		}
+		return nil
+	})
+	v1.Get("/fail", func(ctx *WebContext) error {
+		calls = append(calls, "handler")
+		return errors.New("failed")
+	})
+
+	rr := httptest.NewRecorder()
+	web.WebTest(rr, httptest.NewRequest("GET", "/v1/fail", nil))
+	expected := "global before,group before,handler,group recovered,global after 200"
+	if strings.Join(calls, ",") != expected {
+		t.Errorf("chain called in wrong order: got %v want %v", strings.Join(calls, ","), expected)
+	}
+	if rr.Body.String() != "recovered" {
+		t.Errorf("handler returned unexpected body: got %v want %v", rr.Body.String(), "recovered")
+	}
+
+	//a middleware that does not call Next stops the chain
+	admin, err := web.Group("/admin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	admin.Use(func(ctx *WebContext) error {
+		ctx.Status(401).SendString(strings.NewReader("denied"))
+		return nil
+	})
+	admin.Get("/stats", func(ctx *WebContext) error {
+		t.Errorf("handler called after the chain was stopped")
+		return nil
+	})
+	rr = httptest.NewRecorder()
+	web.WebTest(rr, httptest.NewRequest("GET", "/admin/stats", nil))
+	if rr.Body.String() != "denied" {
+		t.Errorf("handler returned unexpected body: got %v want %v", rr.Body.String(), "denied")
+	}
+}
+
+// go test -v -run TestRouteMiddleware
+func TestRouteMiddleware(t *testing.T) {
+
+	web := New()
+	var calls []string
+	web.Use(func(ctx *WebContext) error {
+		calls = append(calls, "global")
+		return ctx.Next()
+	})
+	v1, err := web.Group("/v1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	v1.Use(func(ctx *WebContext) error {
+		calls = append(calls, "group")
+		return ctx.Next()
+	})
+	auth := func(ctx *WebContext) error {
+		calls = append(calls, "auth")
+		if ctx.Request.Header.Get(Authorization) == "" {
+			ctx.Status(401).SendString(strings.NewReader(MsgInvalidToken))
+			return nil
+		}
+		return ctx.Next()
+	}
+	handler := func(ctx *WebContext) error {
+		calls = append(calls, "handler")
+		ctx.Status(200).SendString(strings.NewReader("OK"))
+		return nil
+	}
+	v1.Get("/private", handler, auth)
+	v1.Get("/public", handler)
+
+	for path, expected := range map[string]string{
+		"/v1/private": "global,group,auth",
+		"/v1/public":  "global,group,handler",
+	} {
+		calls = nil
+		rr := httptest.NewRecorder()
+		web.WebTest(rr, httptest.NewRequest("GET", path, nil))
+		if strings.Join(calls, ",") != expected {
+			t.Errorf("%s: chain called %v want %v", path, strings.Join(calls, ","), expected)
+		}
+	}
+}
+
+// go test -v -run TestHTTPError
+func TestHTTPError(t *testing.T) {
+
+	web := New()
+	web.Get("/forbidden", func(ctx *WebContext) error {
+		return ctx.Next()
+	}, func(ctx *WebContext) error {
+		return NewHTTPError(http.StatusForbidden, "no access").WithCode("forbidden")
+	})
+	web.Get("/fail", func(ctx *WebContext) error {
+		return errors.New("db down")
+	})
+	web.Get("/late", func(ctx *WebContext) error {
+		ctx.Status(200).SendString(strings.NewReader("partial"))
+		return errors.New("failed after writing")
+	})
+
+	for path, expected := range map[string]int{
+		"/forbidden": http.StatusForbidden,
+		"/fail":      http.StatusInternalServerError,
+		"/late":      http.StatusOK,
+	} {
+		rr := httptest.NewRecorder()
+		web.WebTest(rr, httptest.NewRequest("GET", path, nil))
+		if rr.Code != expected {
+			t.Errorf("%s: handler returned wrong status code: got %v want %v", path, rr.Code, expected)
+		}
+	}
+	rr := httptest.NewRecorder()
+	web.WebTest(rr, httptest.NewRequest("GET", "/late", nil))
+	if rr.Body.String() != "partial" {
+		t.Errorf("error written after the response started: got %v", rr.Body.String())
+	}
+}
+
+// go test -v -run TestProblemJSON
+func TestProblemJSON(t *testing.T) {
+
+	web, err := NewWeb(WithErrorHandler(ProblemJSONErrorHandler))
+	if err != nil {
+		t.Fatal(err)
+	}
+	web.Get("/user/{id}", func(ctx *WebContext) error {
+		return NewHTTPError(http.StatusNotFound, "user not found").
+			WithCode("user_not_found").
+			WithDetails(map[string]string{"id": ctx.GetPathValue("id")})
+	})
+	rr := httptest.NewRecorder()
+	web.WebTest(rr, httptest.NewRequest("GET", "/user/42", nil))
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusNotFound)
+	}
+	if ct := rr.Header().Get("Content-Type"); ct != "application/problem+json" {
+		t.Errorf("wrong content type %v", ct)
+	}
+	var p struct {
+		Title    string            `json:"title"`
+		Status   int               `json:"status"`
+		Detail   string            `json:"detail"`
+		Instance string            `json:"instance"`
+		Code     string            `json:"code"`
+		Details  map[string]string `json:"details"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &p); err != nil {
+		t.Fatal(err)
+	}
+	if p.Title != "Not Found" || p.Status != 404 || p.Detail != "user not found" ||
+		p.Instance != "/user/42" || p.Code != "user_not_found" || p.Details["id"] != "42" {
+		t.Errorf("unexpected problem body %+v", p)
+	}
+}
+
+// go test -v -run TestResponseWriter
+func TestResponseWriter(t *testing.T) {
+
+	web := New()
+	var status int
+	var written int64
+	web.Use(func(ctx *WebContext) error {
+		err := ctx.Next()
+		if !ctx.Writer.Written() {
+			t.Errorf("response not marked as written")
+		}
+		status, written = ctx.Writer.StatusCode(), ctx.Writer.BytesWritten()
+		return err
+	})
+	web.Post("/user", func(ctx *WebContext) error {
+
+		return ctx.Status(201).JSON(map[string]string{"name": "David"})
+	})
+	web.Get("/stream", func(ctx *WebContext) error {
+
+		ctx.Status(202).SendString(strings.NewReader("chunk"))
+		return http.NewResponseController(ctx.Writer).Flush()
+	})
+
+	rr := httptest.NewRecorder()
+	web.WebTest(rr, httptest.NewRequest("POST", "/user", nil))
+	if rr.Code != http.StatusCreated || status != http.StatusCreated {
+		t.Errorf("handler returned wrong status code: got %v and %v want %v", rr.Code, status, http.StatusCreated)
+	}
+	if written != int64(rr.Body.Len()) {
+		t.Errorf("wrong bytes written: got %v want %v", written, rr.Body.Len())
+	}
+
+	rr = httptest.NewRecorder()
+	web.WebTest(rr, httptest.NewRequest("GET", "/stream", nil))
+	if rr.Code != http.StatusAccepted {
+		t.Errorf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusAccepted)
+	}
+	if !rr.Flushed {
+		t.Errorf("flush was not passed through")
+	}
+}
+
+// go test -v -run TestBufferResponse
+func TestBufferResponse(t *testing.T) {
+
+	web := New()
+	funcMap := template.FuncMap{
+		"upper": strings.ToUpper,
+	}
+	v1, err := web.Group("/v1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	v1.Use(BufferResponse(1024))
+	v1.Get("/index", func(ctx *WebContext) error {
+
+		//upper expects a string, the template fails after writing the head of the page
+		return ctx.Status(200).RenderFiles("templates/*.html", 42, "index.html", funcMap)
+	})
+	v1.Get("/etag", func(ctx *WebContext) error {
+
+		ctx.Status(200).SendString(strings.NewReader("Hello, world!"))
+		return nil
+	}, func(ctx *WebContext) error {
+		//set an etag once the handler has finished
+		err := ctx.Next()
+		if bw, ok := ctx.Writer.(*BufferedWriter); ok && err == nil {
+			bw.Header().Set("ETag", strconv.Quote(strconv.Itoa(len(bw.Bytes()))))
+		}
+		return err
+	})
+	v1.Get("/large", func(ctx *WebContext) error {
+
+		ctx.Status(200).SendString(strings.NewReader(strings.Repeat("a", 4096)))
+		return nil
+	})
+
+	rr := httptest.NewRecorder()
+	web.WebTest(rr, httptest.NewRequest("GET", "/v1/index", nil))
+	if rr.Code != http.StatusInternalServerError {
+		t.Errorf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusInternalServerError)
+	}
+	if strings.Contains(rr.Body.String(), "<html>") {
+		t.Errorf("partial template output was sent: %v", rr.Body.String())
+	}
+
+	rr = httptest.NewRecorder()
+	web.WebTest(rr, httptest.NewRequest("GET", "/v1/etag", nil))
+	if rr.Header().Get("ETag") != `"13"` || rr.Header().Get("Content-Length") != "13" {
+		t.Errorf("headers not set on the buffered response: %v", rr.Header())
+	}
+	if rr.Body.String() != "Hello, world!" {
+		t.Errorf("handler returned unexpected body: got %v want %v", rr.Body.String(), "Hello, world!")
+	}
+
+	//over the limit the response is streamed
+	rr = httptest.NewRecorder()
+	web.WebTest(rr, httptest.NewRequest("GET", "/v1/large", nil))
+	if rr.Body.Len() != 4096 || rr.Header().Get("Content-Length") != "" {
+		t.Errorf("large response not streamed: %v bytes, headers %v", rr.Body.Len(), rr.Header())
+	}
+}
+
+// go test -v -run TestCorsConfig
+func TestCorsConfig(t *testing.T) {
+
+	web := New().WithCors(CorsConfig{
+		AllowOrigins:     []string{"https://app.example.com", "https://*.example.org"},
+		AllowOriginFunc:  func(origin string) bool { return origin == "http://localhost:3000" },
+		ExposeHeaders:    []string{"X-Request-Id", "X-Total"},
+		AllowCredentials: true,
+		MaxAge:           10 * time.Minute,
+	})
+	handler := func(ctx *WebContext) error {
+
+		ctx.Status(200).SendString(strings.NewReader("OK"))
+		return nil
+	}
+	web.Get("/items", handler)
+	web.Post("/items", handler)
+	internal, err := web.Group("/internal")
+	if err != nil {
+		t.Fatal(err)
+	}
+	internal.WithoutCors().Get("/stats", handler)
+
+	for origin, allowed := range map[string]bool{
+		"https://app.example.com": true,
+		"https://api.example.org": true,
+		"https://example.org":     false,
+		"http://localhost:3000":   true,
+		"https://evil.com":        false,
+	} {
+		req := httptest.NewRequest("GET", "/items", nil)
+		req.Header.Set("Origin", origin)
+		rr := httptest.NewRecorder()
+		web.WebTest(rr, req)
+		got := rr.Header().Get("Access-Control-Allow-Origin")
+		if allowed && (got != origin || rr.Header().Get("Access-Control-Allow-Credentials") != "true") {
+			t.Errorf("%s: origin not allowed: %v", origin, rr.Header())
+		}
+		if !allowed && got != "" {
+			t.Errorf("%s: origin should not be allowed: %v", origin, got)
+		}
+		if rr.Header().Get("Vary") != "Origin" {
+			t.Errorf("%s: missing Vary: Origin", origin)
+		}
+		if allowed && rr.Header().Get("Access-Control-Expose-Headers") != "X-Request-Id, X-Total" {
+			t.Errorf("%s: wrong exposed headers %v", origin, rr.Header().Get("Access-Control-Expose-Headers"))
+		}
+	}
+
+	//no OPTIONS route is registered, the preflight is answered automatically
+	req := httptest.NewRequest("OPTIONS", "/items", nil)
+	req.Header.Set("Origin", "https://app.example.com")
+	req.Header.Set("Access-Control-Request-Method", "POST")
+	req.Header.Set("Access-Control-Request-Headers", "Content-Type")
+	rr := httptest.NewRecorder()
+	web.WebTest(rr, req)
+	if rr.Code != http.StatusNoContent {
+		t.Errorf("preflight returned wrong status code: got %v want %v", rr.Code, http.StatusNoContent)
+	}
+	expected := map[string]string{
+		"Access-Control-Allow-Origin":  "https://app.example.com",
+		"Access-Control-Allow-Methods": "GET, HEAD, POST",
+		"Access-Control-Allow-Headers": "Content-Type",
+		"Access-Control-Max-Age":       "600",
+	}
+	for k, v := range expected {
+		if rr.Header().Get(k) != v {
+			t.Errorf("preflight header %s: got %v want %v", k, rr.Header().Get(k), v)
+		}
+	}
+
+	//a preflight for a path with no route is a 404
+	req = httptest.NewRequest("OPTIONS", "/nope", nil)
+	req.Header.Set("Origin", "https://app.example.com")
+	req.Header.Set("Access-Control-Request-Method", "POST")
+	rr = httptest.NewRecorder()
+	web.WebTest(rr, req)
+	if rr.Code != http.StatusNotFound || rr.Header().Get("Access-Control-Allow-Methods") != "" {
+		t.Errorf("preflight for an unknown path returned %v %v want %v", rr.Code, rr.Header(), http.StatusNotFound)
+	}
+
+	//the group turned cors off
+	req = httptest.NewRequest("GET", "/internal/stats", nil)
+	req.Header.Set("Origin", "https://app.example.com")
+	rr = httptest.NewRecorder()
+	web.WebTest(rr, req)
+	if rr.Header().Get("Access-Control-Allow-Origin") != "" {
+		t.Errorf("group cors override not applied")
+	}
+}
+
+// go test -v -run TestCustomCorsPreflight
+func TestCustomCorsPreflight(t *testing.T) {
+
+	web := New().WithCustomCors([]string{"Content-Type", "Authorization"}, []string{"GET", "POST"})
+	web.Get("/world", func(ctx *WebContext) error {
+
+		ctx.Status(200).SendString(strings.NewReader("Hello, world!"))
+		return nil
+	})
+	req := httptest.NewRequest("OPTIONS", "/world", nil)
+	req.Header.Set("Origin", "https://example.com")
+	req.Header.Set("Access-Control-Request-Method", "GET")
+	rr := httptest.NewRecorder()
+	web.WebTest(rr, req)
+	if rr.Header().Get("Access-Control-Allow-Methods") != "GET, POST" ||
+		rr.Header().Get("Access-Control-Allow-Headers") != "Content-Type, Authorization" ||
+		rr.Header().Get("Access-Control-Allow-Origin") != "*" {
+		t.Errorf("custom cors headers not applied: %v", rr.Header())
+	}
+}
+
+// go test -v -run TestAllowedMethods
+func TestAllowedMethods(t *testing.T) {
+
+	web := New()
+	handler := func(ctx *WebContext) error {
+
+		ctx.Status(200).SendString(strings.NewReader(ctx.Request.Method))
+		return nil
+	}
+	web.Get("/items", handler)
+	web.Post("/items", handler)
+	web.Handle("PROPFIND", "/dav/{file}", handler)
+	web.Any("/any", handler)
+	if err := web.Handle("BAD METHOD", "/dav", handler); err == nil {
+		t.Errorf("expected an error for an invalid method")
+	}
+
+	//OPTIONS is answered with the registered methods
+	rr := httptest.NewRecorder()
+	web.WebTest(rr, httptest.NewRequest("OPTIONS", "/items", nil))
+	if rr.Code != http.StatusNoContent || rr.Header().Get("Allow") != "GET, HEAD, POST, OPTIONS" {
+		t.Errorf("OPTIONS returned %v with Allow %q", rr.Code, rr.Header().Get("Allow"))
+	}
+
+	//405 goes through the error handler with the Allow header
+	rr = httptest.NewRecorder()
+	web.WebTest(rr, httptest.NewRequest("DELETE", "/items", nil))
+	if rr.Code != http.StatusMethodNotAllowed || rr.Header().Get("Allow") != "GET, HEAD, POST, OPTIONS" {
+		t.Errorf("DELETE returned %v with Allow %q", rr.Code, rr.Header().Get("Allow"))
+	}
+	rr = httptest.NewRecorder()
+	web.WebTest(rr, httptest.NewRequest("GET", "/dav/a.txt", nil))
+	if rr.Code != http.StatusMethodNotAllowed || rr.Header().Get("Allow") != "PROPFIND, OPTIONS" {
+		t.Errorf("GET returned %v with Allow %q", rr.Code, rr.Header().Get("Allow"))
+	}
+
+	//HEAD is served by the GET handler without a body
+	rr = httptest.NewRecorder()
+	web.WebTest(rr, httptest.NewRequest("HEAD", "/items", nil))
+	if rr.Code != http.StatusOK || rr.Body.Len() != 0 {
+		t.Errorf("HEAD returned %v with body %q", rr.Code, rr.Body.String())
+	}
+
+	for method, path := range map[string]string{"PROPFIND": "/dav/a.txt", "REPORT": "/any", "PUT": "/any"} {
+		rr = httptest.NewRecorder()
+		web.WebTest(rr, httptest.NewRequest(method, path, nil))
+		if rr.Code != http.StatusOK || rr.Body.String() != method {
+			t.Errorf("%s %s returned %v %q", method, path, rr.Code, rr.Body.String())
+		}
+	}
+}
+
+// go test -v -run TestNotFoundHandlers
+func TestNotFoundHandlers(t *testing.T) {
+
+	web := New()
+	var seen []string
+	web.Use(func(ctx *WebContext) error {
+		err := ctx.Next()
+		seen = append(seen, ctx.Request.URL.Path)
+		return err
+	})
+	web.NotFound(func(ctx *WebContext) error {
+		return ctx.Status(404).JSON(map[string]string{"error": "not_found"})
+	})
+	web.MethodNotAllowed(func(ctx *WebContext) error {
+		return ctx.Status(405).JSON(map[string]string{"error": "method_not_allowed", "allow": ctx.Writer.Header().Get("Allow")})
+	})
+	api, err := web.Group("/api")
+	if err != nil {
+		t.Fatal(err)
+	}
+	api.NotFound(func(ctx *WebContext) error {
+		return ctx.Status(404).JSON(map[string]string{"error": "no such api"})
+	})
+	api.Post("/save", func(ctx *WebContext) error {
+
+		ctx.Status(200).SendString(strings.NewReader("OK"))
+		return nil
+	})
+
+	for _, tc := range []struct {
+		method, path string
+		status       int
+		body         map[string]string
+	}{
+		{"GET", "/missing", 404, map[string]string{"error": "not_found"}},
+		{"GET", "/api/missing", 404, map[string]string{"error": "no such api"}},
+		{"GET", "/api/save", 405, map[string]string{"error": "method_not_allowed", "allow": "POST, OPTIONS"}},
+	} {
+		rr := httptest.NewRecorder()
+		web.WebTest(rr, httptest.NewRequest(tc.method, tc.path, nil))
+		if rr.Code != tc.status {
+			t.Errorf("%s %s: wrong status code: got %v want %v", tc.method, tc.path, rr.Code, tc.status)
+		}
+		var body map[string]string
+		if err := json.Unmarshal(rr.Body.Bytes(), &body); err != nil {
+			t.Fatalf("%s %s: %v", tc.method, tc.path, err)
+		}
+		for k, v := range tc.body {
+			if body[k] != v {
+				t.Errorf("%s %s: body %s got %v want %v", tc.method, tc.path, k, body[k], v)
+			}
+		}
+	}
+	if strings.Join(seen, ",") != "/missing,/api/missing,/api/save" {
+		t.Errorf("global middleware did not run for unmatched requests: %v", seen)
+	}
+}
+
+// go test -v -run TestURLFor
+func TestURLFor(t *testing.T) {
+
+	web := New()
+	handler := func(ctx *WebContext) error {
+		return nil
+	}
+	v1, err := web.Group("/v1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := v1.Named("user").Get("/user/{id}", handler); err != nil {
+		t.Fatal(err)
+	}
+	web.Named("files").Get("/files/{path...}", handler)
+	web.Named("home").Get("/{$}", handler)
+	if err := web.Named("user").Post("/other", handler); err == nil {
+		t.Errorf("expected an error for a duplicate route name")
+	}
+
+	for _, tc := range []struct {
+		name     string
+		params   []any
+		expected string
+	}{
+		{"user", []any{"id", 42}, "/v1/user/42"},
+		{"user", []any{"id", "a b/c"}, "/v1/user/a%20b%2Fc"},
+		{"files", []any{"path", "docs/read me.txt"}, "/files/docs/read%20me.txt"},
+		{"home", nil, "/"},
+	} {
+		got, err := web.URLFor(tc.name, tc.params...)
+		if err != nil || got != tc.expected {
+			t.Errorf("URLFor(%s, %v) = %v, %v want %v", tc.name, tc.params, got, err, tc.expected)
+		}
+	}
+	for _, params := range [][]any{{}, {"id"}, {"id", 1, "page", 2}} {
+		if _, err := web.URLFor("user", params...); err == nil {
+			t.Errorf("expected an error for the params %v", params)
+		}
+	}
+	if _, err := web.URLFor("missing"); err == nil {
+		t.Errorf("expected an error for an unknown route")
+	}
+
+	//urlFor is available in the templates
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "link.html"), []byte(`<a href="{{ urlFor "user" "id" . }}">user</a>`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	web.Get("/link", func(ctx *WebContext) error {
+		return ctx.Status(200).RenderFiles(filepath.Join(dir, "*.html"), 7, "link.html", nil)
+	})
+	rr := httptest.NewRecorder()
+	web.WebTest(rr, httptest.NewRequest("GET", "/link", nil))
+	if rr.Body.String() != `<a href="/v1/user/7">user</a>` {
+		t.Errorf("template returned unexpected body: got %v", rr.Body.String())
+	}
+}
+
+// go test -v -run TestTypedParams
+func TestTypedParams(t *testing.T) {
+
+	web := New()
+	web.Get("/item/{id}/{uuid}", func(ctx *WebContext) error {
+		id, err := ctx.PathInt("id")
+		if err != nil {
+			return err
+		}
+		uuid, err := ctx.PathUUID("uuid")
+		if err != nil {
+			return err
+		}
+		page, err := ctx.QueryIntDefault("page", 1)
+		if err != nil {
+			return err
+		}
+		ttl, err := ctx.QueryDurationDefault("ttl", time.Minute)
+		if err != nil {
+			return err
+		}
+		ids, err := ctx.QueryInts("n")
+		if err != nil {
+			return err
+		}
+		return ctx.Status(200).SendString(strings.NewReader(fmt.Sprintf("%d %s %d %s %v", id, uuid, page, ttl, ids)))
+	})
+
+	const uuid = "0c6f3e2a-8a4b-4d2e-9f3a-1b2c3d4e5f60"
+	for _, tc := range []struct {
+		target string
+		status int
+		body   string
+	}{
+		{"/item/7/" + uuid, 200, "7 " + uuid + " 1 1m0s []"},
+		{"/item/7/" + uuid + "?page=3&ttl=2s&n=1&n=2", 200, "7 " + uuid + " 3 2s [1 2]"},
+		{"/item/x/" + uuid, 400, `invalid path parameter "id", expected an integer`},
+		{"/item/7/nope", 400, `invalid path parameter "uuid", expected a UUID`},
+		{"/item/7/" + uuid + "?page=two", 400, `invalid query parameter "page", expected an integer`},
+		{"/item/7/" + uuid + "?n=1&n=x", 400, `invalid query parameter "n", expected an integer`},
+	} {
+		rr := httptest.NewRecorder()
+		web.WebTest(rr, httptest.NewRequest("GET", tc.target, nil))
+		if rr.Code != tc.status {
+			t.Errorf("handler returned wrong status code for %s: got %v want %v", tc.target, rr.Code, tc.status)
+		}
+		if !strings.Contains(rr.Body.String(), tc.body) {
+			t.Errorf("handler returned unexpected body for %s: got %v want %v", tc.target, rr.Body.String(), tc.body)
+		}
+	}
+
+	u, err := ParseUUID(uuid)
+	if err != nil || u.String() != uuid {
+		t.Errorf("ParseUUID returned %v, %v want %v", u, err, uuid)
+	}
+}
+
+// go test -v -run TestBind
+func TestBind(t *testing.T) {
+
+	type Paging struct {
+		Page int `query:"page" default:"1"`
+		Size int `query:"size" default:"20"`
+	}
+	type request struct {
+		Paging
+		ID      UUID          `path:"id"`
+		Tags    []string      `query:"tag"`
+		Since   *time.Time    `query:"since" layout:"2006-01-02"`
+		TTL     time.Duration `query:"ttl" default:"1m"`
+		Tenant  string        `header:"X-Tenant"`
+		Session string        `cookie:"sid"`
+		Name    string        `json:"name"`
+		Count   int           `json:"count" default:"3"`
+	}
+
+	var got request
+	web := New()
+	web.Post("/item/{id}", func(ctx *WebContext) error {
+		got = request{}
+		if err := ctx.Bind(&got); err != nil {
+			return err
+		}
+		return ctx.Status(200).JSON(got)
+	})
+
+	const uuid = "0c6f3e2a-8a4b-4d2e-9f3a-1b2c3d4e5f60"
+	req := httptest.NewRequest("POST", "/item/"+uuid+"?page=2&tag=a&tag=b", strings.NewReader(`{"name":"gweb"}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Tenant", "acme")
+	req.AddCookie(&http.Cookie{Name: "sid", Value: "s1"})
+	rr := httptest.NewRecorder()
+	web.WebTest(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("handler returned wrong status code: got %v want %v, %v", rr.Code, http.StatusOK, rr.Body.String())
+	}
+	if got.ID.String() != uuid || got.Page != 2 || got.Size != 20 || len(got.Tags) != 2 || got.Since != nil ||
+		got.TTL != time.Minute || got.Tenant != "acme" || got.Session != "s1" || got.Name != "gweb" || got.Count != 3 {
+		t.Errorf("Bind returned unexpected values: got %+v", got)
+	}
+
+	//every conversion error is returned together
+	req = httptest.NewRequest("POST", "/item/nope?page=x&since=yesterday", strings.NewReader(`{"count":"many"}`))
+	req.Header.Set("Content-Type", "application/json")
+	rr = httptest.NewRecorder()
+	web.WebTest(rr, req)
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusBadRequest)
+	}
+	for _, field := range []string{"json count", "path id", "query page", "query since"} {
+		if !strings.Contains(rr.Body.String(), field) {
+			t.Errorf("handler returned unexpected body: got %v want %v", rr.Body.String(), field)
+		}
+	}
+
+	//the body can not set the fields that come from a header, a cookie or the query
+	var spoofed struct {
+		Tenant string `header:"X-Tenant"`
+		Role   string `cookie:"role"`
+		Page   int    `query:"page"`
+		Name   string `json:"name"`
+	}
+	web.Post("/spoof", func(ctx *WebContext) error {
+		if err := ctx.Bind(&spoofed); err != nil {
+			return err
+		}
+		return ctx.Status(200).JSON(spoofed)
+	})
+	req = httptest.NewRequest("POST", "/spoof", strings.NewReader(`{"name":"bob","Tenant":"other","role":"admin","Page":9}`))
+	req.Header.Set("Content-Type", "application/json")
+	rr = httptest.NewRecorder()
+	web.WebTest(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Errorf("handler returned wrong status code: got %v want %v, %v", rr.Code, http.StatusOK, rr.Body.String())
+	}
+	if spoofed.Name != "bob" || spoofed.Tenant != "" || spoofed.Role != "" || spoofed.Page != 0 {
+		t.Errorf("Bind set the fields from the body: got %+v", spoofed)
+	}
+
+	var notStruct int
+	wc := &WebContext{Request: httptest.NewRequest("GET", "/", nil)}
+	if err := wc.Bind(&notStruct); err == nil {
+		t.Errorf("expected an error for a pointer to an int")
+	}
+}
+
+type signup struct {
+	Email    string   `json:"email" validate:"required,email"`
+	Password string   `json:"password" validate:"required,min=8"`
+	Confirm  string   `json:"confirm"`
+	Plan     string   `json:"plan" validate:"oneof=free pro"`
+	Site     string   `json:"site" validate:"omitempty,url"`
+	Tags     []string `json:"tags" validate:"max=3,dive,regex=^[a-z]+$"`
+	Items    []struct {
+		SKU string `json:"sku" validate:"required,even"`
+	} `json:"items"`
+}
+
+func (s signup) Validate() error {
+	if s.Password != s.Confirm {
+		return FieldError{Field: "confirm", Message: "must match the password"}
+	}
+	return nil
+}
+
+// go test -v -run TestValidate
+func TestValidate(t *testing.T) {
+
+	web, err := NewWeb(WithErrorHandler(ProblemJSONErrorHandler))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = web.RegisterValidation("even", func(value any, _ string) error {
+		if len(value.(string))%2 != 0 {
+			return errors.New("must have an even length")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	web.Post("/signup", func(ctx *WebContext) error {
+		var req signup
+		if err := ctx.Bind(&req); err != nil {
+			return err
+		}
+		if err := ctx.Validate(&req); err != nil {
+			return err
+		}
+		return ctx.Status(201).JSON(req)
+	})
+
+	valid := `{"email":"a@b.io","password":"secret12","confirm":"secret12","plan":"pro","tags":["go"],"items":[{"sku":"ab"}]}`
+	invalid := `{"email":"nope","password":"short","confirm":"other","plan":"gold","site":"x","tags":["go","Web"],"items":[{"sku":"abc"},{}]}`
+	for _, tc := range []struct {
+		body   string
+		status int
+		fields string
+	}{
+		{valid, http.StatusCreated, ""},
+		{invalid, http.StatusUnprocessableEntity,
+			"email email,password min,plan oneof,site url,tags[1] regex,items[0].sku even,items[1].sku required,confirm validate"},
+	} {
+		req := httptest.NewRequest("POST", "/signup", strings.NewReader(tc.body))
+		req.Header.Set("Content-Type", "application/json")
+		rr := httptest.NewRecorder()
+		web.WebTest(rr, req)
+		if rr.Code != tc.status {
+			t.Errorf("handler returned wrong status code: got %v want %v, %v", rr.Code, tc.status, rr.Body.String())
+		}
+		if tc.fields == "" {
+			continue
+		}
+		var p struct {
+			Details []FieldError `json:"details"`
+		}
+		if err := json.NewDecoder(rr.Body).Decode(&p); err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, fe := range p.Details {
+			got = append(got, fe.Field+" "+fe.Rule)
+		}
+		if strings.Join(got, ",") != tc.fields {
+			t.Errorf("handler returned unexpected fields: got %v want %v", strings.Join(got, ","), tc.fields)
+		}
+	}
+
+	//the custom rule is only registered on the Web
+	var req signup
+	json.Unmarshal([]byte(valid), &req)
+	if err := Validate(&req); err == nil || errors.As(err, new(*HTTPError)) {
+		t.Errorf("expected an unknown rule error, got %v", err)
+	}
+	if err := web.RegisterValidation("", nil); err == nil {
+		t.Errorf("expected an error for an empty rule")
+	}
+}
+
+// go test -v -run TestParseBody
+func TestParseBody(t *testing.T) {
+
+	type contact struct {
+		Name  string   `json:"name" xml:"name" form:"name"`
+		Age   int      `json:"age" xml:"age" form:"age"`
+		Langs []string `json:"langs" xml:"lang" form:"lang"`
+	}
+	web := New()
+	web.RegisterDecoder("text/csv", func(r *http.Request, v any) error {
+		b, err := io.ReadAll(r.Body)
+		if err != nil {
+			return err
+		}
+		parts := strings.Split(strings.TrimSpace(string(b)), ",")
+		c := v.(*contact)
+		c.Name = parts[0]
+		c.Age, err = strconv.Atoi(parts[1])
+		return err
+	})
+	web.Post("/contact", func(ctx *WebContext) error {
+		var c contact
+		if err := ctx.ParseBody(&c); err != nil {
+			return err
+		}
+		return ctx.Status(200).SendString(strings.NewReader(fmt.Sprintf("%s %d %v", c.Name, c.Age, c.Langs)))
+	})
+
+	var multipartBody bytes.Buffer
+	mw := multipart.NewWriter(&multipartBody)
+	mw.WriteField("name", "ann")
+	mw.WriteField("age", "30")
+	mw.WriteField("lang", "go")
+	mw.Close()
+
+	for _, tc := range []struct {
+		contentType string
+		body        string
+		status      int
+		expected    string
+	}{
+		{"", `{"name":"ann","age":30,"langs":["go"]}`, 200, "ann 30 [go]"},
+		{"application/json; charset=utf-8", `{"name":"ann","age":30}`, 200, "ann 30 []"},
+		{"application/xml", `<contact><name>ann</name><age>30</age><lang>go</lang><lang>c</lang></contact>`, 200, "ann 30 [go c]"},
+		{"application/x-www-form-urlencoded", "name=ann&age=30&lang=go&lang=c", 200, "ann 30 [go c]"},
+		{mw.FormDataContentType(), multipartBody.String(), 200, "ann 30 [go]"},
+		{"text/csv", "ann,30", 200, "ann 30 []"},
+		{"application/x-www-form-urlencoded", "name=ann&age=old", 400, "form age: expected an integer"},
+		{"application/json", `{"name":`, 400, "invalid JSON body"},
+		{"application/yaml", "name: ann", 415, "unsupported Content-Type application/yaml"},
+	} {
+		req := httptest.NewRequest("POST", "/contact", strings.NewReader(tc.body))
+		if tc.contentType != "" {
+			req.Header.Set("Content-Type", tc.contentType)
+		}
+		rr := httptest.NewRecorder()
+		web.WebTest(rr, req)
+		if rr.Code != tc.status {
+			t.Errorf("handler returned wrong status code for %q: got %v want %v", tc.contentType, rr.Code, tc.status)
+		}
+		if !strings.Contains(rr.Body.String(), tc.expected) {
+			t.Errorf("handler returned unexpected body for %q: got %v want %v", tc.contentType, rr.Body.String(), tc.expected)
+		}
+	}
+	if err := web.RegisterDecoder("", nil); err == nil {
+		t.Errorf("expected an error for an invalid media type")
+	}
+}
+
+// go test -v -run TestJSONOptions
+func TestJSONOptions(t *testing.T) {
+
+	web, err := NewWeb(WithJSONOptions(JSONOptions{MaxBytes: 32, DisallowUnknownFields: true, SingleValue: true}),
+		WithErrorHandler(ProblemJSONErrorHandler))
+	if err != nil {
+		t.Fatal(err)
+	}
+	handler := func(ctx *WebContext) error {
+		var body struct {
+			Name string `json:"name"`
+			Meta any    `json:"meta"`
+		}
+		if err := ctx.ParseBody(&body); err != nil {
+			return err
+		}
+		return ctx.Status(200).SendString(strings.NewReader(fmt.Sprintf("%s %T", body.Name, body.Meta)))
+	}
+	web.Post("/strict", handler)
+	web.Post("/loose", handler, UseJSONOptions(JSONOptions{UseNumber: true}))
+
+	for _, tc := range []struct {
+		path   string
+		body   string
+		status int
+		code   string
+	}{
+		{"/strict", `{"name":"gweb","meta":1}`, 200, "gweb float64"},
+		{"/strict", `{"name":"gweb","extra":1}`, 400, "unknown_field"},
+		{"/strict", `{"name":"gweb"} {"name":"x"}`, 400, "trailing_data"},
+		{"/strict", `{"name":"` + strings.Repeat("a", 64) + `"}`, 413, "body_too_large"},
+		{"/strict", `{"name":1}`, 400, "invalid_request"},
+		{"/strict", `{"name"`, 400, "invalid_json"},
+		{"/strict", ``, 400, "empty_body"},
+		{"/loose", `{"name":"` + strings.Repeat("a", 64) + `","meta":1,"extra":1} {}`, 200, "json.Number"},
+	} {
+		req := httptest.NewRequest("POST", tc.path, strings.NewReader(tc.body))
+		req.Header.Set("Content-Type", "application/json")
+		rr := httptest.NewRecorder()
+		web.WebTest(rr, req)
+		if rr.Code != tc.status {
+			t.Errorf("handler returned wrong status code for %s: got %v want %v", tc.body, rr.Code, tc.status)
+		}
+		if !strings.Contains(rr.Body.String(), tc.code) {
+			t.Errorf("handler returned unexpected body for %s: got %v want %v", tc.body, rr.Body.String(), tc.code)
+		}
+	}
+	if _, err := NewWeb(WithJSONOptions(JSONOptions{MaxBytes: -1})); err == nil {
+		t.Errorf("expected an error for negative max bytes")
+	}
+}
+
+// go test -v -run TestUploads
+func TestUploads(t *testing.T) {
+
+	png := append([]byte("\x89PNG\r\n\x1a\n"), bytes.Repeat([]byte{0}, 100)...)
+	upload := func(files map[string][]byte) (*bytes.Buffer, string) {
+		var body bytes.Buffer
+		mw := multipart.NewWriter(&body)
+		mw.WriteField("title", "holiday")
+		for name, content := range files {
+			fw, _ := mw.CreateFormFile("photo", name)
+			fw.Write(content)
+		}
+		mw.Close()
+		return &body, mw.FormDataContentType()
+	}
+
+	dir := t.TempDir()
+	var tempPath string
+	web, err := NewWeb(WithUploadLimits(UploadLimits{MaxFileSize: 200, MaxFiles: 2, AllowedTypes: []string{"image/*"}, TempDir: dir}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	web.Post("/photo", func(ctx *WebContext) error {
+		f, err := ctx.FormFile("photo")
+		if err != nil {
+			return err
+		}
+		tempPath = f.path
+		if err := ctx.SaveUploadedFile(f, filepath.Join(dir, "saved.png")); err != nil {
+			return err
+		}
+		return ctx.Status(200).SendString(strings.NewReader(fmt.Sprintf("%s %s %d %s", f.Filename, f.ContentType, f.Size, ctx.Request.PostFormValue("title"))))
+	})
+	var streamed bytes.Buffer
+	web.Post("/stream", func(ctx *WebContext) error {
+		files, err := ctx.StreamFiles(func(f *UploadedFile) (io.Writer, error) {
+			return &streamed, nil
+		})
+		if err != nil {
+			return err
+		}
+		return ctx.Status(200).SendString(strings.NewReader(strconv.Itoa(len(files))))
+	}, UseUploadLimits(UploadLimits{MaxTotalSize: 1000}))
+
+	for _, tc := range []struct {
+		path   string
+		files  map[string][]byte
+		status int
+		body   string
+	}{
+		{"/photo", map[string][]byte{"../me.png": png}, 200, "me.png image/png 108 holiday"},
+		{"/photo", map[string][]byte{"big.png": append(png, make([]byte, 200)...)}, 413, `file "big.png" larger than 200 bytes`},
+		{"/photo", map[string][]byte{"me.png": []byte("plain text, named like an image")}, 415, "text/plain is not allowed"},
+		{"/photo", map[string][]byte{"a.png": png, "b.png": png, "c.png": png}, 413, "more than 2 files"},
+		{"/photo", nil, 400, `missing file "photo"`},
+		{"/stream", map[string][]byte{"notes.txt": []byte("notes")}, 200, "1"},
+		{"/stream", map[string][]byte{"big.bin": make([]byte, 1001)}, 413, "files larger than 1000 bytes"},
+	} {
+		body, contentType := upload(tc.files)
+		req := httptest.NewRequest("POST", tc.path, body)
+		req.Header.Set("Content-Type", contentType)
+		rr := httptest.NewRecorder()
+		web.WebTest(rr, req)
+		if rr.Code != tc.status {
+			t.Errorf("handler returned wrong status code for %v: got %v want %v, %v", tc.path, rr.Code, tc.status, rr.Body.String())
+		}
+		if !strings.Contains(rr.Body.String(), tc.body) {
+			t.Errorf("handler returned unexpected body: got %v want %v", rr.Body.String(), tc.body)
+		}
+	}
+	//the writer got the start of the file that was too large
+	if !strings.HasPrefix(streamed.String(), "notes") {
+		t.Errorf("stream returned unexpected content: got %v", streamed.String())
+	}
+	if saved, _ := os.ReadFile(filepath.Join(dir, "saved.png")); !bytes.Equal(saved, png) {
+		t.Errorf("saved file has unexpected content")
+	}
+	//the temp file is removed at the end of the request
+	if _, err := os.Stat(tempPath); tempPath == "" || !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("expected the temp file %q to be removed, got %v", tempPath, err)
+	}
+
+	rr := httptest.NewRecorder()
+	web.WebTest(rr, httptest.NewRequest("POST", "/photo", strings.NewReader("{}")))
+	if rr.Code != http.StatusUnsupportedMediaType {
+		t.Errorf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusUnsupportedMediaType)
+	}
+}
+
+// go test -v -run TestTusUpload
+func TestTusUpload(t *testing.T) {
+
+	store, err := NewTusFileStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var completed TusInfo
+	var content string
+	tus, err := NewTusServer(TusConfig{
+		Store:      store,
+		MaxSize:    1024,
+		Expiration: time.Hour,
+		OnComplete: []WebHandler{func(ctx *WebContext) error {
+			completed, _ = TusUploadInfo(ctx)
+			rc, err := store.Open(ctx.Request.Context(), completed.ID)
+			if err != nil {
+				return err
+			}
+			defer rc.Close()
+			b, err := io.ReadAll(rc)
+			content = string(b)
+			return err
+		}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	web := New()
+	files, _ := web.Group("/files")
+	if err := tus.Mount(files); err != nil {
+		t.Fatal(err)
+	}
+
+	send := func(method string, target string, body string, headers ...string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		req.Header.Set("Tus-Resumable", TusVersion)
+		for i := 0; i+1 < len(headers); i += 2 {
+			req.Header.Set(headers[i], headers[i+1])
+		}
+		rr := httptest.NewRecorder()
+		web.WebTest(rr, req)
+		return rr
+	}
+
+	rr := send("OPTIONS", "/files/", "")
+	if rr.Code != http.StatusNoContent || rr.Header().Get("Tus-Extension") != tusExtensions || rr.Header().Get("Tus-Max-Size") != "1024" {
+		t.Errorf("OPTIONS returned %v %v", rr.Code, rr.Header())
+	}
+	rr = send("POST", "/files/", "", "Upload-Length", "11", "Upload-Metadata", "filename aGVsbG8udHh0,private")
+	location := rr.Header().Get("Location")
+	if rr.Code != http.StatusCreated || !strings.HasPrefix(location, "/files/") || rr.Header().Get("Upload-Expires") == "" {
+		t.Fatalf("POST returned %v %v", rr.Code, rr.Header())
+	}
+	//the clients are usually given the endpoint without the trailing slash, it must not redirect
+	rr = send("OPTIONS", "/files", "")
+	if rr.Code != http.StatusNoContent || rr.Header().Get("Tus-Extension") != tusExtensions {
+		t.Errorf("OPTIONS /files returned %v %v", rr.Code, rr.Header())
+	}
+	rr = send("POST", "/files", "", "Upload-Length", "3")
+	if rr.Code != http.StatusCreated || !strings.HasPrefix(rr.Header().Get("Location"), "/files/") {
+		t.Errorf("POST /files returned %v %v", rr.Code, rr.Header())
+	}
+	for _, tc := range []struct {
+		name    string
+		method  string
+		body    string
+		headers []string
+		status  int
+	}{
+		{"wrong version", "HEAD", "", []string{"Tus-Resumable", "0.2.2"}, http.StatusPreconditionFailed},
+		{"first chunk", "PATCH", "hello", []string{"Content-Type", tusChunkType, "Upload-Offset", "0"}, http.StatusNoContent},
+		{"stale offset", "PATCH", "hello", []string{"Content-Type", tusChunkType, "Upload-Offset", "0"}, http.StatusConflict},
+		{"wrong content type", "PATCH", " world", []string{"Content-Type", "text/plain", "Upload-Offset", "5"}, http.StatusUnsupportedMediaType},
+		{"offset", "HEAD", "", nil, http.StatusOK},
+		{"last chunk", "PATCH", " world", []string{"Content-Type", tusChunkType, "Upload-Offset", "5"}, http.StatusNoContent},
+	} {
+		rr := send(tc.method, location, tc.body, tc.headers...)
+		if rr.Code != tc.status {
+			t.Errorf("%s returned wrong status code: got %v want %v, %v", tc.name, rr.Code, tc.status, rr.Body.String())
+		}
+		if rr.Header().Get("Tus-Resumable") != TusVersion {
+			t.Errorf("%s is missing Tus-Resumable", tc.name)
+		}
+		if tc.name == "offset" && (rr.Header().Get("Upload-Offset") != "5" || rr.Header().Get("Upload-Metadata") != "filename aGVsbG8udHh0,private") {
+			t.Errorf("HEAD returned unexpected headers: %v", rr.Header())
+		}
+	}
+	if content != "hello world" || completed.Metadata["filename"] != "hello.txt" || !completed.Complete() {
+		t.Errorf("OnComplete got %+v with %q", completed, content)
+	}
+
+	if rr := send("POST", "/files/", "", "Upload-Length", "2048"); rr.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("POST returned wrong status code: got %v want %v", rr.Code, http.StatusRequestEntityTooLarge)
+	}
+	if rr := send("DELETE", location, ""); rr.Code != http.StatusNoContent {
+		t.Errorf("DELETE returned wrong status code: got %v want %v", rr.Code, http.StatusNoContent)
+	}
+	if rr := send("HEAD", location, ""); rr.Code != http.StatusNotFound {
+		t.Errorf("HEAD returned wrong status code: got %v want %v", rr.Code, http.StatusNotFound)
+	}
+
+	//an expired upload is gone
+	ctx := context.Background()
+	store.Create(ctx, TusInfo{ID: "abc123", Length: 10, ExpiresAt: time.Now().Add(-time.Minute)})
+	if removed, err := tus.CleanupExpired(ctx); removed != 1 || err != nil {
+		t.Errorf("CleanupExpired returned %v, %v want 1", removed, err)
+	}
+	//the locks of unknown and finished uploads are not kept
+	for i := 0; i < 10; i++ {
+		send("PATCH", "/files/"+strconv.Itoa(i), "x", "Content-Type", tusChunkType, "Upload-Offset", "0")
+	}
+	if len(tus.locks) != 0 {
+		t.Errorf("expected no upload locks, got %d", len(tus.locks))
+	}
+	if _, err := store.Info(ctx, "../abc"); !errors.Is(err, ErrUploadNotFound) {
+		t.Errorf("expected ErrUploadNotFound for an invalid id, got %v", err)
+	}
+}
+
+// go test -v -run TestContextValues
+func TestContextValues(t *testing.T) {
+
+	type user struct {
+		Name string
+	}
+	type traceKey struct{}
+	auth := func(ctx *WebContext) error {
+		ctx.Set("user", &user{Name: "ann"})
+		ctx.Set(traceKey{}, "t-1")
+		return ctx.Next()
+	}
+	web := New()
+	web.Get("/me", func(ctx *WebContext) error {
+		u, ok := Value[*user](ctx, "user")
+		if !ok {
+			return NewHTTPError(http.StatusUnauthorized, "")
+		}
+		if _, ok := Value[string](ctx, "user"); ok {
+			return errors.New("expected the wrong type to fail")
+		}
+		//a plain string key of another package does not see it
+		if ctx.Context().Value("user") != nil {
+			return errors.New("expected the string key to be wrapped")
+		}
+		trace, _ := ctx.Get(traceKey{})
+		return ctx.Status(200).SendString(strings.NewReader(u.Name + " " + trace.(string)))
+	}, auth)
+	web.Get("/std", WrapHandler(http.HandlerFunc(func(wr http.ResponseWriter, r *http.Request) {
+		io.WriteString(wr, r.Context().Value(traceKey{}).(string))
+	})), auth)
+
+	for path, expected := range map[string]string{"/me": "ann t-1", "/std": "t-1"} {
+		rr := httptest.NewRecorder()
+		web.WebTest(rr, httptest.NewRequest("GET", path, nil))
+		if rr.Code != http.StatusOK || rr.Body.String() != expected {
+			t.Errorf("handler returned %v %v want %v", rr.Code, rr.Body.String(), expected)
+		}
+	}
+}
+
+type createItem struct {
+	Shop string `path:"shop"`
+	Name string `json:"name" xml:"name" validate:"required"`
+	Qty  int    `json:"qty" xml:"qty" validate:"min=1"`
+}
+
+type itemCreated struct {
+	XMLName xml.Name `json:"-" xml:"item"`
+	ID      string   `json:"id" xml:"id"`
+	Shop    string   `json:"shop" xml:"shop"`
+}
+
+func (itemCreated) StatusCode() int {
+	return http.StatusCreated
+}
+
+// go test -v -run TestTyped
+func TestTyped(t *testing.T) {
+
+	web := New()
+	web.RegisterEncoder("text/csv", func(w io.Writer, v any) error {
+		item := v.(*itemCreated)
+		_, err := fmt.Fprintf(w, "%s,%s\n", item.ID, item.Shop)
+		return err
+	})
+	web.Post("/shops/{shop}/items", Typed(func(ctx context.Context, req createItem) (*itemCreated, error) {
+		if req.Name == "taken" {
+			return nil, NewHTTPError(http.StatusConflict, "name taken")
+		}
+		return &itemCreated{ID: req.Name + "-" + strconv.Itoa(req.Qty), Shop: req.Shop}, nil
+	}))
+	web.Delete("/shops/{shop}", Typed(func(ctx context.Context, req struct{}) (*itemCreated, error) {
+		return nil, nil
+	}))
+
+	for _, tc := range []struct {
+		contentType string
+		accept      string
+		body        string
+		status      int
+		expected    string
+	}{
+		{"application/json", "", `{"name":"pen","qty":2}`, 201, `{"id":"pen-2","shop":"s1"}`},
+		{"application/xml", "application/xml", `<createItem><name>pen</name><qty>3</qty></createItem>`, 201, `<item><id>pen-3</id><shop>s1</shop></item>`},
+		{"application/json", "text/html;q=0.9, text/csv", `{"name":"pen","qty":2}`, 201, "pen-2,s1"},
+		{"application/json", "image/png", `{"name":"pen","qty":2}`, 406, "no acceptable type"},
+		{"application/json", "application/json;q=0, */*;q=0.5", `{"name":"pen","qty":2}`, 201, `<item><id>pen-2</id><shop>s1</shop></item>`},
+		{"application/json", "*/*;q=0, application/json", `{"name":"pen","qty":2}`, 201, `{"id":"pen-2","shop":"s1"}`},
+		{"application/json", "*/*;q=0", `{"name":"pen","qty":2}`, 406, "no acceptable type"},
+		{"application/json", "", `{"qty":0}`, 422, "name: is required"},
+		{"application/json", "", `{"name":"pen","qty":"two"}`, 400, "json qty: expected an integer"},
+		{"application/json", "", `{"name":"taken","qty":1}`, 409, "name taken"},
+		{"", "", `{"name":"pen","qty":4}`, 201, `{"id":"pen-4","shop":"s1"}`},
+		{"application/x-www-form-urlencoded", "", "name=pen&qty=5&Shop=other", 201, `{"id":"pen-5","shop":"s1"}`},
+		{"application/json", "", `{"name":"pen","qty":6,"Shop":"other"}`, 201, `{"id":"pen-6","shop":"s1"}`},
+	} {
+		req := httptest.NewRequest("POST", "/shops/s1/items", strings.NewReader(tc.body))
+		if tc.contentType != "" {
+			req.Header.Set("Content-Type", tc.contentType)
+		}
+		if tc.accept != "" {
+			req.Header.Set("Accept", tc.accept)
+		}
+		rr := httptest.NewRecorder()
+		web.WebTest(rr, req)
+		if rr.Code != tc.status {
+			t.Errorf("handler returned wrong status code for %s: got %v want %v, %v", tc.body, rr.Code, tc.status, rr.Body.String())
+		}
+		if !strings.Contains(rr.Body.String(), tc.expected) {
+			t.Errorf("handler returned unexpected body: got %v want %v", rr.Body.String(), tc.expected)
+		}
+	}
+
+	//a Req without tags is decoded like ParseBody does
+	type person struct {
+		Name string
+		Age  int
+	}
+	web.Post("/people", Typed(func(ctx context.Context, req person) (person, error) {
+		return req, nil
+	}))
+	req := httptest.NewRequest("POST", "/people", strings.NewReader(`{"Name":"bob","Age":3}`))
+	req.Header.Set("Content-Type", "application/json")
+	rr := httptest.NewRecorder()
+	web.WebTest(rr, req)
+	if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), `{"Name":"bob","Age":3}`) {
+		t.Errorf("handler returned %v %v want %v", rr.Code, rr.Body.String(), `{"Name":"bob","Age":3}`)
+	}
+
+	rr = httptest.NewRecorder()
+	web.WebTest(rr, httptest.NewRequest("DELETE", "/shops/s1", nil))
+	if rr.Code != http.StatusNoContent || rr.Body.Len() != 0 {
+		t.Errorf("handler returned %v %v want %v", rr.Code, rr.Body.String(), http.StatusNoContent)
+	}
+}
+
+// go test -v -run TestMultipartCleanup
+func TestMultipartCleanup(t *testing.T) {
+
+	dir := t.TempDir()
+	t.Setenv("TMPDIR", dir)
+	var during int
+	web := New()
+	web.Post("/upload", func(ctx *WebContext) error {
+		//Set replaces the request, the temp files must still be removed
+		ctx.Set("user", "ann")
+		var form struct {
+			Title string `form:"title"`
+		}
+		if err := ctx.Bind(&form); err != nil {
+			return err
+		}
+		entries, _ := os.ReadDir(dir)
+		during = len(entries)
+		return ctx.Status(200).SendString(strings.NewReader(form.Title))
+	})
+
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	mw.WriteField("title", "big")
+	fw, _ := mw.CreateFormFile("file", "big.bin")
+	//larger than the memory of the form so it goes to a temp file
+	fw.Write(make([]byte, defaultMultipartMemory+1<<20))
+	mw.Close()
+	req := httptest.NewRequest("POST", "/upload", &body)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	rr := httptest.NewRecorder()
+	web.WebTest(rr, req)
+	if rr.Code != http.StatusOK || rr.Body.String() != "big" {
+		t.Fatalf("handler returned %v %v", rr.Code, rr.Body.String())
+	}
+	entries, _ := os.ReadDir(dir)
+	if during == 0 || len(entries) != 0 {
+		t.Errorf("expected the temp files to be removed: %d during the request, %d after", during, len(entries))
+	}
+}
@@ -1,8 +1,12 @@
 package gweb
 
 import (
+	"context"
+	"net"
 	"net/http"
 	"net/url"
+	"os"
+	"sync"
 
 	"log/slog"
 )
@@ -10,8 +14,8 @@ import (
 // a web handler type
 type WebHandler func(wc *WebContext) error
 
-// the gloabal web instance
-var w *Web
+// Hook ... a function called when the server starts or shuts down
+type Hook func(ctx context.Context) error
 
 // Web struct which handles all the connection
 type Web struct {
@@ -20,33 +24,93 @@ type Web struct {
 	middlewares []WebHandler
 
 	router *http.ServeMux
+	//the router wrapped by the http middlewares
+	handler         http.Handler
+	httpMiddlewares []func(http.Handler) http.Handler
+	//the group of every pattern registered on the router, nil for routes on the Web
+	routes map[string]*WebGroup
+	//the methods probed to find the methods allowed for a path
+	methods []string
+	//a route registered for all the methods on "/", it gets every request no other route matched
+	rootRoute http.Handler
+	groups    []*WebGroup
+	//the path pattern of every named route
+	names map[string]string
+	//the custom rules used by WebContext.Validate
+	validations map[string]ValidationRule
+	//the decoders used by WebContext.ParseBody for other media types
+	decoders map[string]BodyDecoder
+	//the encoders used by WebContext.Respond for other media types
+	encoders map[string]BodyEncoder
+	//how the JSON bodies are decoded, nil for the defaults
+	jsonOptions *JSONOptions
+	//the limits of the multipart uploads, nil for no limits
+	uploadLimits *UploadLimits
+	//handlers for the requests no route matched, nil for the defaults
+	notFound         WebHandler
+	methodNotAllowed WebHandler
 
 	//enable Gloabl logging
 	logging bool
-	//enable cors support for all the routes
-	defaultCors bool
+	//the cors settings for all the routes, nil if cors is disabled
+	cors   *CorsConfig
+	WebLog *slog.Logger
+	//writes the response for the errors returned by middlewares and handlers
+	ErrorHandler ErrorHandler
 
-	//if we need custom cors headers and methods support
-	customHeader []string
-	custMethods  []string
-	WebLog       *slog.Logger
+	//lifecycle hooks
+	onStart    []Hook
+	onShutdown []Hook
+
+	//guards the listeners the server is running on
+	mu        sync.Mutex
+	listeners []net.Listener
+	//permissions of the socket file created by RunUnix
+	socketMode os.FileMode
 }
 
+// WebGroup ... a group of routes under a common prefix, groups can be nested
 type WebGroup struct {
-	router      *http.ServeMux
-	pattern     string
+	//the full prefix including the prefixes of the parent groups
+	prefix      string
+	parent      *WebGroup
 	w           *Web
 	middlewares []WebHandler
+
+	//nil means the setting is inherited from the parent group or the Web
+	logging          *bool
+	cors             *corsSettings
+	notFound         WebHandler
+	methodNotAllowed WebHandler
+}
+
+// corsSettings ... the cors settings of a WebGroup, a nil config disables cors
+type corsSettings struct {
+	config *CorsConfig
 }
 
 // WebContext ... the context for each copnnection
 type WebContext struct {
-	Writer  http.ResponseWriter
+	Writer  ResponseWriter
 	Request *http.Request
 	query   url.Values
 	//set by the handler
 	ReplyStatus int
 	WebLog      *slog.Logger
+
+	web *Web
+	//the middlewares and the handler for this request, index is the one running
+	handlers []WebHandler
+	index    int
+	//the JSON options of the route, nil to use the ones of the Web
+	jsonOptions *JSONOptions
+	//the upload limits of the route, nil to use the ones of the Web
+	uploadLimits *UploadLimits
+	//the files of the multipart body once it has been read
+	uploads     []*UploadedFile
+	uploadsRead bool
+	//run when the request is done, for example to remove the temp files
+	cleanups []func()
 }
 
 // GwebMessage received for this Gweb Service
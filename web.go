@@ -2,37 +2,65 @@ package gweb
 
 import (
 	"errors"
-	"log"
+	"fmt"
 	"os"
+	"slices"
 
 	"net/http"
 	"strings"
-	"sync"
 	"time"
 
 	"log/slog"
 )
 
+// the pattern of the handler for the requests no route matched
+const fallbackPattern = "/"
+
+// New ... creates a new Web instance with the default settings
+// kept for backward compatibility, use NewWeb to pass options
 func New() *Web {
-	// initweb will be called only once even from different threads
-	sync.OnceFunc(func() {
-		router := http.NewServeMux()
-		httpServer := &http.Server{
-			Addr:           "",
-			Handler:        router,
-			ReadTimeout:    10 * time.Second,
-			WriteTimeout:   10 * time.Second,
-			MaxHeaderBytes: 1 << 20,
+	w, _ := NewWeb()
+	return w
+}
+
+// NewWeb ... creates a new Web instance with its own router, middlewares, logger and cors settings
+// every call returns a fresh instance so multiple servers can run in the same process
+// opts ... optional settings applied in order, the first failing option is returned as an error
+func NewWeb(opts ...WebOption) (*Web, error) {
+	router := http.NewServeMux()
+	httpServer := &http.Server{
+		Addr:           "",
+		ReadTimeout:    10 * time.Second,
+		WriteTimeout:   10 * time.Second,
+		MaxHeaderBytes: 1 << 20,
+	}
+	w := &Web{
+		httpServer:  httpServer,
+		middlewares: make([]WebHandler, 0),
+		router:      router,
+		handler:     router,
+		routes:      make(map[string]*WebGroup),
+		names:       make(map[string]string),
+		methods: []string{http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut,
+			http.MethodPatch, http.MethodDelete, http.MethodOptions},
+		WebLog:       slog.New(slog.NewJSONHandler(os.Stdout, nil)),
+		ErrorHandler: DefaultErrorHandler,
+		socketMode:   0660,
+	}
+	httpServer.Handler = w
+	//catches the requests no route matched
+	router.HandleFunc(fallbackPattern, w.fallback)
+	for _, opt := range opts {
+		if opt == nil {
+			continue
 		}
-		w = &Web{
-			httpServer:  httpServer,
-			middlewares: make([]WebHandler, 0),
-			router:      router,
-			WebLog:      slog.New(slog.NewJSONHandler(os.Stdout, nil)),
+		if err := opt(w); err != nil {
+			return nil, err
 		}
-	})()
-
-	return w
+	}
+	//errors from the http server go to the same logger as the rest of gweb
+	httpServer.ErrorLog = slog.NewLogLogger(w.WebLog.Handler(), slog.LevelError)
+	return w, nil
 }
 
 // enable global logging for all the routes
@@ -41,106 +69,265 @@ func (w *Web) WithLogging() *Web {
 	return w
 }
 
-// enable default cors
-func (w *Web) WithDefaultCors() *Web {
-	w.defaultCors = true
-	return w
-}
+// addRoutes ... adds the route to the default mux
+// wg ... the group the route belongs to, nil for routes added on the Web
+// middlewares ... run only for this route after the global and group middlewares
+func (w *Web) addRoutes(pattern string, f WebHandler, wg *WebGroup, middlewares ...WebHandler) error {
 
-// apply CORS with custom headers and methods
-func (w *Web) WithCustomCors(headers []string, methods []string) *Web {
-	if headers != nil {
-		w.customHeader = headers
+	if f == nil {
+		return errors.New(InternalServerError)
 	}
-	if methods != nil {
-		w.customHeader = methods
+	routeMiddlewares := make([]WebHandler, 0, len(middlewares)+1)
+	for _, m := range middlewares {
+		if m != nil {
+			routeMiddlewares = append(routeMiddlewares, m)
+		}
 	}
-	return w
+	routeMiddlewares = append(routeMiddlewares, f)
+	handler := func(wr http.ResponseWriter, r *http.Request) {
+		//the global middlewares first then the ones from the outermost group to the route's group
+		w.serveRoute(wr, r, wg, append(w.middlewaresFor(wg), routeMiddlewares...))
+	}
+	return w.handle(pattern, http.HandlerFunc(handler), wg)
 }
 
-// Run ... create a HTTP server and runs it on the host address provided
-// host ... it should be in the "ip:port" format
-// returns any error thorwn by the http server
-func (w *Web) Run(host string) error {
-	w.httpServer.Addr = host
-	w.WebLog.Info("Running Gweb server", "host", host)
-	return w.httpServer.ListenAndServe()
+// serveRoute ... runs the chain of handlers for the request with the cors, error handling and logging settings of wg
+func (w *Web) serveRoute(wr http.ResponseWriter, r *http.Request, wg *WebGroup, handlers []WebHandler) {
+	if wr == nil || r == nil {
+		return
+	}
+	wc := &WebContext{
 
-}
+		WebLog:   w.WebLog,
+		web:      w,
+		handlers: handlers,
+		index:    -1,
+	}
 
-// addRoutes ... adds the route to the default mux
-func (w *Web) addRoutes(pattern string, f WebHandler, wg ...*WebGroup) {
+	wc.Request = r
+	defer wc.cleanup()
+	rw := newResponseWriter(wr)
+	//HEAD is served by the GET handler without the body
+	rw.discardBody = r.Method == http.MethodHead
+	wc.Writer = rw
+	preflight := false
+	if cors := w.corsFor(wg); cors != nil {
+		//write cors headers
+		preflight = middlewareCors(wc, cors, w.allowedMethods)
+	}
+	if preflight {
+		//the browser does not send credentials with a preflight, so the middlewares are skipped
+		wc.Writer.WriteHeader(http.StatusNoContent)
+	} else if err := wc.Next(); err != nil {
+		wc.SendError(err)
+	}
+	if !wc.Writer.Written() {
+		wc.Writer.WriteHeader(wc.Writer.StatusCode())
+	}
+	wc.ReplyStatus = wc.Writer.StatusCode()
+	if w.loggingFor(wg) {
+		middlewareLogger(wc)
+	}
+}
 
-	if f == nil {
+// fallback ... handles the requests no route matched
+// OPTIONS and cors preflights are answered for any registered path, otherwise it is a 404 or a 405
+func (w *Web) fallback(wr http.ResponseWriter, r *http.Request) {
+	if w.rootRoute != nil {
+		//a route registered with Any("/") catches everything
+		w.rootRoute.ServeHTTP(wr, r)
 		return
 	}
-	middlewares := make([]WebHandler, 0)
-	copy(middlewares, w.middlewares)
-	handler := func(wr http.ResponseWriter, r *http.Request) {
-		if wr == nil || r == nil {
-			return
+	methods := w.allowedMethods(r)
+	if len(methods) == 0 {
+		w.serveNotFound(wr, r, w.groupForPath(r.URL.Path))
+		return
+	}
+	if !slices.Contains(methods, http.MethodOptions) {
+		methods = append(methods, http.MethodOptions)
+	}
+	allow := strings.Join(methods, ", ")
+	wg := w.groupFor(r)
+	wr.Header().Set("Allow", allow)
+	if r.Method == http.MethodOptions {
+		w.serveRoute(wr, r, wg, []WebHandler{func(wc *WebContext) error {
+			wc.Status(http.StatusNoContent)
+			return nil
+		}})
+		return
+	}
+	w.serveRoute(wr, r, wg, append(w.middlewaresFor(wg), w.methodNotAllowedFor(wg)))
+}
+
+// serveNotFound ... runs the NotFound handler of wg through the middlewares
+func (w *Web) serveNotFound(wr http.ResponseWriter, r *http.Request, wg *WebGroup) {
+	w.serveRoute(wr, r, wg, append(w.middlewaresFor(wg), w.notFoundFor(wg)))
+}
+
+// notFoundFor ... the NotFound handler of the closest group that set one or the global one
+func (w *Web) notFoundFor(wg *WebGroup) WebHandler {
+	for g := wg; g != nil; g = g.parent {
+		if g.notFound != nil {
+			return g.notFound
 		}
-		wc := &WebContext{
+	}
+	if w.notFound != nil {
+		return w.notFound
+	}
+	return notFoundHandler
+}
 
-			WebLog: w.WebLog,
+// methodNotAllowedFor ... the MethodNotAllowed handler of the closest group that set one or the global one
+func (w *Web) methodNotAllowedFor(wg *WebGroup) WebHandler {
+	for g := wg; g != nil; g = g.parent {
+		if g.methodNotAllowed != nil {
+			return g.methodNotAllowed
 		}
-		//save the middlewares that needs to be called for this route
+	}
+	if w.methodNotAllowed != nil {
+		return w.methodNotAllowed
+	}
+	return methodNotAllowedHandler
+}
 
-		wc.Request = r
-		wc.Writer = wr
-		for _, r := range middlewares {
+// groupForPath ... the deepest group whose prefix contains the path
+func (w *Web) groupForPath(path string) *WebGroup {
+	var found *WebGroup
+	for _, g := range w.groups {
+		if g.prefix != "" && path != g.prefix && !strings.HasPrefix(path, g.prefix+"/") {
+			continue
+		}
+		if found == nil || len(g.prefix) > len(found.prefix) {
+			found = g
+		}
+	}
+	return found
+}
 
-			e := r(wc)
-			if e != nil {
+// notFoundHandler ... the default NotFound handler
+func notFoundHandler(wc *WebContext) error {
+	return NewHTTPError(http.StatusNotFound, "")
+}
+
+// methodNotAllowedHandler ... the default MethodNotAllowed handler, the Allow header is already set
+func methodNotAllowedHandler(wc *WebContext) error {
+	return NewHTTPError(http.StatusMethodNotAllowed, "")
+}
 
-				http.Error(wr, e.Error(), http.StatusBadRequest)
-				return
-			}
+// NotFound ... the handler for the requests no route matched, it runs after the global middlewares
+// the default replies with a 404 through the ErrorHandler
+func (w *Web) NotFound(f WebHandler) {
+	w.notFound = f
+}
+
+// MethodNotAllowed ... the handler for a path registered with other methods, it runs after the global middlewares
+// the Allow header is already set, the default replies with a 405 through the ErrorHandler
+func (w *Web) MethodNotAllowed(f WebHandler) {
+	w.methodNotAllowed = f
+}
+
+// allowedMethods ... the methods with a route matching the path of the request
+func (w *Web) allowedMethods(r *http.Request) []string {
+	var methods []string
+	probe := *r
+	for _, m := range w.methods {
+		probe.Method = m
+		if _, pattern := w.router.Handler(&probe); pattern != "" && pattern != fallbackPattern {
+			methods = append(methods, m)
 		}
-		if w.defaultCors {
-			//write cors headers
-			middlewareCorsDefault(wc)
-		} else if w.custMethods != nil || w.customHeader != nil {
-			middlewareCorsCustom(wc, w.customHeader, w.custMethods)
+	}
+	return methods
+}
+
+// groupFor ... the group of a route matching the path of the request with any method
+func (w *Web) groupFor(r *http.Request) *WebGroup {
+	probe := *r
+	for _, m := range w.methods {
+		probe.Method = m
+		if _, pattern := w.router.Handler(&probe); pattern != "" && pattern != fallbackPattern {
+			return w.routes[pattern]
 		}
+	}
+	return nil
+}
 
-		err := f(wc)
-		if err != nil {
-			wc.SendError(err)
+// handle ... registers the handler on the router and remembers the group it belongs to
+// the router panics on invalid or conflicting patterns, it is returned as an error
+func (w *Web) handle(pattern string, h http.Handler, wg *WebGroup) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%s: %v", InvalidPattern, r)
 		}
-		if wc.ReplyStatus == 0 {
-			wc.ReplyStatus = http.StatusOK
-			wc.Writer.WriteHeader(http.StatusOK)
+	}()
+	if pattern == fallbackPattern {
+		if w.rootRoute != nil {
+			return fmt.Errorf("%s: %q is already registered", InvalidPattern, pattern)
 		}
-		if w.logging {
-			middlewareLogger(wc)
+		w.rootRoute = h
+	} else {
+		w.router.Handle(pattern, h)
+	}
+	w.routes[pattern] = wg
+	return nil
+}
+
+// middlewaresFor ... the global middlewares followed by the middlewares of wg and its parents
+func (w *Web) middlewaresFor(wg *WebGroup) []WebHandler {
+	var groups []*WebGroup
+	for g := wg; g != nil; g = g.parent {
+		groups = append(groups, g)
+	}
+	middlewares := make([]WebHandler, 0, len(w.middlewares))
+	middlewares = append(middlewares, w.middlewares...)
+	for i := len(groups) - 1; i >= 0; i-- {
+		middlewares = append(middlewares, groups[i].middlewares...)
+	}
+	return middlewares
+}
+
+// loggingFor ... the logging setting of the closest group that set one or the global one
+func (w *Web) loggingFor(wg *WebGroup) bool {
+	for g := wg; g != nil; g = g.parent {
+		if g.logging != nil {
+			return *g.logging
 		}
 	}
-	if len(wg) > 0 {
-		middlewares = append(middlewares, wg[0].middlewares...)
-		wg[0].router.HandleFunc(pattern, handler)
+	return w.logging
+}
 
-	} else {
-		w.router.HandleFunc(pattern, handler)
+// corsFor ... the cors config of the closest group that set one or the global one, nil if disabled
+func (w *Web) corsFor(wg *WebGroup) *CorsConfig {
+	for g := wg; g != nil; g = g.parent {
+		if g.cors != nil {
+			return g.cors.config
+		}
 	}
+	return w.cors
+}
 
+// Group ... group the routes under the prefix, groups can be nested with WebGroup.Group
+// the group inherits the global middlewares, cors and logging settings
+// prefix ... should start with /, for example /v1
+func (w *Web) Group(prefix string) (*WebGroup, error) {
+	return w.newGroup(prefix, nil)
 }
 
-// group the routes
-func (w *Web) Group(pattern string) *WebGroup {
-	v := WebGroup{
-		router:      http.NewServeMux(),
-		pattern:     pattern,
+// newGroup ... creates a group under parent, nil parent for a top level group
+func (w *Web) newGroup(prefix string, parent *WebGroup) (*WebGroup, error) {
+	if !strings.HasPrefix(prefix, "/") || strings.ContainsAny(prefix, " \t\n") {
+		return nil, fmt.Errorf("%s: %q", InvalidPath, prefix)
+	}
+	v := &WebGroup{
+		prefix:      strings.TrimSuffix(prefix, "/"),
+		parent:      parent,
 		w:           w,
 		middlewares: make([]WebHandler, 0),
 	}
-	if !strings.HasPrefix(pattern, "/") {
-		w.WebLog.Error("Invalid path")
-		log.Fatal()
+	if parent != nil {
+		v.prefix = parent.prefix + v.prefix
 	}
-
-	w.router.Handle("/", v.router)
-	return &v
+	w.groups = append(w.groups, v)
+	return v, nil
 }
 
 // add middlewares using Use
@@ -152,86 +339,149 @@ func (w *Web) Use(f WebHandler) {
 }
 
 // GET ... adds a GET handler
-func (w *Web) Get(pattern string, f WebHandler) error {
+// middlewares ... optional middlewares only for this route, they run after the global and group middlewares
+func (w *Web) Get(pattern string, f WebHandler, middlewares ...WebHandler) error {
 	if f == nil {
 		return errors.New(InternalServerError)
 	}
 	if !strings.HasPrefix(pattern, "/") {
 		return errors.New(InvalidPath)
 	}
-
-	w.addRoutes(http.MethodGet+" "+pattern, f)
-	return nil
+	return w.addRoutes(http.MethodGet+" "+pattern, f, nil, middlewares...)
 }
 
 // Post ... adds a POST handler
-func (w *Web) Post(pattern string, f WebHandler) error {
+func (w *Web) Post(pattern string, f WebHandler, middlewares ...WebHandler) error {
 	if f == nil {
 		return errors.New(InternalServerError)
 	}
 	if !strings.HasPrefix(pattern, "/") {
 		return errors.New(InvalidPath)
 	}
-	w.addRoutes(http.MethodPost+" "+pattern, f)
-	return nil
-
+	return w.addRoutes(http.MethodPost+" "+pattern, f, nil, middlewares...)
 }
 
 // Delete ... adds a DELETE handler
-func (w *Web) Delete(pattern string, f WebHandler) error {
+func (w *Web) Delete(pattern string, f WebHandler, middlewares ...WebHandler) error {
 	if f == nil {
 		return errors.New(InternalServerError)
 	}
 	if !strings.HasPrefix(pattern, "/") {
 		return errors.New(InvalidPath)
 	}
-	w.addRoutes(http.MethodDelete+" "+pattern, f)
-	return nil
+	return w.addRoutes(http.MethodDelete+" "+pattern, f, nil, middlewares...)
 }
 
 // Put ... adds a PUT handler
-func (w *Web) Put(pattern string, f WebHandler) error {
+func (w *Web) Put(pattern string, f WebHandler, middlewares ...WebHandler) error {
 	if f == nil {
 		return errors.New(InternalServerError)
 	}
 	if !strings.HasPrefix(pattern, "/") {
 		return errors.New(InvalidPath)
 	}
-	w.addRoutes(http.MethodPut+" "+pattern, f)
-	return nil
-
+	return w.addRoutes(http.MethodPut+" "+pattern, f, nil, middlewares...)
 }
 
 // Options ... options Verb support
-func (w *Web) Options(pattern string, f WebHandler) error {
+func (w *Web) Options(pattern string, f WebHandler, middlewares ...WebHandler) error {
 	if f == nil {
 		return errors.New(InternalServerError)
 	}
 	if !strings.HasPrefix(pattern, "/") {
 		return errors.New(InvalidPath)
 	}
-	w.addRoutes(http.MethodOptions+" "+pattern, f)
-	return nil
-
+	return w.addRoutes(http.MethodOptions+" "+pattern, f, nil, middlewares...)
 }
 
 // Patch ... Patch service
-func (w *Web) Patch(pattern string, f WebHandler) error {
+func (w *Web) Patch(pattern string, f WebHandler, middlewares ...WebHandler) error {
 	if f == nil {
 		return errors.New(InternalServerError)
 	}
 	if !strings.HasPrefix(pattern, "/") {
 		return errors.New(InvalidPath)
 	}
-	w.addRoutes(http.MethodPatch+" "+pattern, f)
+	return w.addRoutes(http.MethodPatch+" "+pattern, f, nil, middlewares...)
+}
+
+// Any ... adds a handler for all the methods of the pattern
+func (w *Web) Any(pattern string, f WebHandler, middlewares ...WebHandler) error {
+	if f == nil {
+		return errors.New(InternalServerError)
+	}
+	if !strings.HasPrefix(pattern, "/") {
+		return errors.New(InvalidPath)
+	}
+	return w.addRoutes(pattern, f, nil, middlewares...)
+}
+
+// Handle ... adds a handler for any method, for example PROPFIND or REPORT
+func (w *Web) Handle(method string, pattern string, f WebHandler, middlewares ...WebHandler) error {
+	if f == nil {
+		return errors.New(InternalServerError)
+	}
+	if !strings.HasPrefix(pattern, "/") {
+		return errors.New(InvalidPath)
+	}
+	if err := w.addMethod(method); err != nil {
+		return err
+	}
+	return w.addRoutes(method+" "+pattern, f, nil, middlewares...)
+}
+
+// addMethod ... validates the method and adds it to the methods probed for the Allow header
+func (w *Web) addMethod(method string) error {
+	if !isToken(method) {
+		return fmt.Errorf("%s: %q", InvalidMethod, method)
+	}
+	if !slices.Contains(w.methods, method) {
+		w.methods = append(w.methods, method)
+	}
 	return nil
 }
 
-// for writing unit test
+// the characters allowed in a method name
+const tokenChars = "!#$%&'*+-.^_`|~0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+
+// isToken ... true if s is a valid HTTP token
+func isToken(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, c := range s {
+		if !strings.ContainsRune(tokenChars, c) {
+			return false
+		}
+	}
+	return true
+}
+
+// for writing unit test, same as ServeHTTP
 func (w *Web) WebTest(wr http.ResponseWriter, r *http.Request) {
 	if wr == nil || r == nil {
 		return
 	}
-	w.router.ServeHTTP(wr, r)
+	w.ServeHTTP(wr, r)
 
 }
+
+// ServeHTTP ... makes Web a http.Handler so it can be mounted in another server or used with httptest
+// the request goes through the same handlers, middlewares, cors and logging as with Run
+func (w *Web) ServeHTTP(wr http.ResponseWriter, r *http.Request) {
+	w.handler.ServeHTTP(wr, r)
+}
+
+// UseHandler ... wrap the Web with a standard func(http.Handler) http.Handler middleware
+// the first wrapper added is the outermost, it applies to Run and ServeHTTP
+func (w *Web) UseHandler(mw func(http.Handler) http.Handler) {
+	if mw == nil {
+		return
+	}
+	w.httpMiddlewares = append(w.httpMiddlewares, mw)
+	var h http.Handler = w.router
+	for i := len(w.httpMiddlewares) - 1; i >= 0; i-- {
+		h = w.httpMiddlewares[i](h)
+	}
+	w.handler = h
+}
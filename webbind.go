@@ -0,0 +1,475 @@
+package gweb
+
+import (
+	"encoding"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// the tags read by Bind, in the order they are looked up on a field
+var bindSources = []string{"path", "query", "header", "cookie", "form"}
+
+// defaultMultipartMemory ... the memory used for a multipart form before the files go to disk
+const defaultMultipartMemory = 32 << 20
+
+// FieldError ... why a field could not be bound or validated
+type FieldError struct {
+	//the name of the parameter, header, cookie or JSON field, a path like items[0].name for validation
+	Field string `json:"field"`
+	//where the value came from: path, query, header, cookie, form or json
+	Source string `json:"source,omitempty"`
+	//the validation rule that failed and its parameter
+	Rule  string `json:"rule,omitempty"`
+	Param string `json:"param,omitempty"`
+	//the value that was received
+	Value string `json:"value,omitempty"`
+	//what was wrong with the value
+	Message string `json:"message"`
+}
+
+// Error ... the field followed by the message
+func (e FieldError) Error() string {
+	if e.Field == "" {
+		return e.Message
+	}
+	if e.Source != "" {
+		return e.Source + " " + e.Field + ": " + e.Message
+	}
+	return e.Field + ": " + e.Message
+}
+
+var (
+	timeType          = reflect.TypeOf(time.Time{})
+	durationType      = reflect.TypeOf(time.Duration(0))
+	textUnmarshalType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+// Bind ... fills the struct pointed to by v from the request
+// each field is tagged with where its value comes from: path:"id", query:"page", header:"X-Tenant",
+// cookie:"sid", form:"name" or json:"name" for a field of a JSON body, the body only fills the fields with a json tag
+// the values are converted to the type of the field, slices get every value, pointers stay nil when there is no value
+// and a default:"10" tag is used when the request has none, embedded structs are bound as well
+// every field that fails to convert is returned together in a 400 HTTPError with a FieldError for each
+func (wc *WebContext) Bind(v any) error {
+	return wc.bind(v, false)
+}
+
+// bind ... binds v like Bind, with parseBody the body is decoded by ParseBody whatever its type
+// into the fields that do not come from the path, the query, a header or a cookie
+func (wc *WebContext) bind(v any, parseBody bool) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("%s: %T", InvalidBindTarget, v)
+	}
+	b := binder{wc: wc, parseBody: parseBody}
+	//defaults of the json fields are set first so the body can replace them
+	if err := b.bindStruct(rv.Elem(), true); err != nil {
+		return err
+	}
+	switch {
+	case parseBody:
+		if hasBody(wc.Request) {
+			if err := b.decodeBody(rv.Elem(), wc.ParseBody, isBodyField); err != nil {
+				return err
+			}
+		}
+	case b.hasJSON:
+		if err := b.decodeJSON(rv.Elem()); err != nil {
+			return err
+		}
+	}
+	if err := b.bindStruct(rv.Elem(), false); err != nil {
+		return err
+	}
+	return b.err()
+}
+
+// binder ... the state of one call to Bind
+type binder struct {
+	wc         *WebContext
+	parseBody  bool
+	hasJSON    bool
+	formParsed bool
+	errs       []FieldError
+}
+
+// err ... the 400 HTTPError with every field that failed, nil if none did
+func (b *binder) err() error {
+	if len(b.errs) == 0 {
+		return nil
+	}
+	messages := make([]string, len(b.errs))
+	for i, fe := range b.errs {
+		messages[i] = fe.Error()
+	}
+	return NewHTTPError(http.StatusBadRequest, "invalid request, "+strings.Join(messages, "; ")).
+		WithCode("invalid_request").
+		WithDetails(b.errs)
+}
+
+// bindStruct ... binds the fields of a struct, jsonPhase only sets the defaults of the json fields
+// an error is only returned for a field type that can not be bound
+func (b *binder) bindStruct(rv reflect.Value, jsonPhase bool) error {
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		field := rt.Field(i)
+		fv := rv.Field(i)
+		if field.Anonymous {
+			ft := field.Type
+			if ft.Kind() == reflect.Pointer {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct && ft != timeType {
+				if fv.Kind() == reflect.Pointer {
+					if fv.IsNil() {
+						if !fv.CanSet() {
+							continue
+						}
+						fv.Set(reflect.New(ft))
+					}
+					fv = fv.Elem()
+				}
+				if err := b.bindStruct(fv, jsonPhase); err != nil {
+					return err
+				}
+				continue
+			}
+		}
+		if !field.IsExported() {
+			continue
+		}
+		if jsonPhase {
+			name, ok := field.Tag.Lookup("json")
+			if !ok || name == "-" {
+				continue
+			}
+			b.hasJSON = true
+			if def, ok := field.Tag.Lookup("default"); ok {
+				if err := b.setField(fv, field, "json", jsonName(field, name), defaultValues(fv, def)); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+		for _, source := range bindSources {
+			if source == "form" && b.parseBody {
+				//the form was read with the body
+				continue
+			}
+			name, ok := field.Tag.Lookup(source)
+			if !ok || name == "" || name == "-" {
+				continue
+			}
+			values := b.values(source, name)
+			if len(values) == 0 {
+				def, ok := field.Tag.Lookup("default")
+				if !ok {
+					break
+				}
+				values = defaultValues(fv, def)
+			}
+			if err := b.setField(fv, field, source, name, values); err != nil {
+				return err
+			}
+			break
+		}
+	}
+	return nil
+}
+
+// values ... the values of the named parameter in the source, nil if there are none
+func (b *binder) values(source string, name string) []string {
+	r := b.wc.Request
+	switch source {
+	case "path":
+		if v := r.PathValue(name); v != "" {
+			return []string{v}
+		}
+	case "query":
+		if b.wc.query == nil {
+			b.wc.query = r.URL.Query()
+		}
+		return b.wc.query[name]
+	case "header":
+		return r.Header.Values(name)
+	case "cookie":
+		var values []string
+		for _, c := range r.Cookies() {
+			if c.Name == name {
+				values = append(values, c.Value)
+			}
+		}
+		return values
+	case "form":
+		if !b.formParsed {
+			b.formParsed = true
+			err := b.wc.parseMultipartForm(r)
+			if err != nil && !errors.Is(err, http.ErrNotMultipart) {
+				b.errs = append(b.errs, FieldError{Field: name, Source: source, Message: "invalid form body"})
+			}
+		}
+		return r.PostForm[name]
+	}
+	return nil
+}
+
+// decodeJSON ... decodes a JSON body into the json fields of the struct, a body with another Content-Type is ignored
+func (b *binder) decodeJSON(rv reflect.Value) error {
+	r := b.wc.Request
+	if r.Body == nil || r.Body == http.NoBody {
+		return nil
+	}
+	//like ParseBody a body without a Content-Type is JSON
+	mediaType := "application/json"
+	if contentType := r.Header.Get("Content-Type"); contentType != "" {
+		mediaType, _, _ = mime.ParseMediaType(contentType)
+	}
+	if mediaType != "application/json" && !strings.HasSuffix(mediaType, "+json") {
+		return nil
+	}
+	return b.decodeBody(rv, func(v any) error {
+		return b.wc.decodeJSON(r, v)
+	}, isJSONField)
+}
+
+// decodeBody ... decodes the body into the fields of the struct that keep allows
+// the body is decoded into a copy so it can not set the fields bound from a header, a cookie or the query
+// the fields that do not decode are added to the errors, any other failure is returned
+func (b *binder) decodeBody(rv reflect.Value, decode func(v any) error, keep func(field reflect.StructField) bool) error {
+	tmp := reflect.New(rv.Type())
+	tmp.Elem().Set(rv)
+	cloneEmbedded(tmp.Elem())
+	err := decode(tmp.Interface())
+	copyFields(rv, tmp.Elem(), keep)
+	var httpErr *HTTPError
+	if !errors.As(err, &httpErr) {
+		return err
+	}
+	switch httpErr.Code {
+	case "empty_body":
+		return nil
+	case "invalid_request", "unknown_field":
+		b.errs = append(b.errs, httpErr.Details.([]FieldError)...)
+		return nil
+	}
+	return err
+}
+
+// cloneEmbedded ... gives the copy of a struct its own embedded structs so decoding into it leaves the original alone
+func cloneEmbedded(rv reflect.Value) {
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		field := rt.Field(i)
+		fv := rv.Field(i)
+		if !field.Anonymous || !isStruct(field.Type) {
+			continue
+		}
+		if fv.Kind() == reflect.Pointer {
+			if fv.IsNil() || !fv.CanSet() {
+				continue
+			}
+			clone := reflect.New(field.Type.Elem())
+			clone.Elem().Set(fv.Elem())
+			fv.Set(clone)
+			fv = clone
+		}
+		cloneEmbedded(reflect.Indirect(fv))
+	}
+}
+
+// copyFields ... copies the fields that keep allows from src to dst, embedded structs included
+func copyFields(dst reflect.Value, src reflect.Value, keep func(field reflect.StructField) bool) {
+	rt := dst.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		field := rt.Field(i)
+		dv, sv := dst.Field(i), src.Field(i)
+		if field.Anonymous && isStruct(field.Type) {
+			if sv.Kind() == reflect.Pointer {
+				if sv.IsNil() || !dv.CanSet() {
+					continue
+				}
+				if dv.IsNil() {
+					dv.Set(reflect.New(field.Type.Elem()))
+				}
+				dv, sv = dv.Elem(), sv.Elem()
+			}
+			copyFields(dv, sv, keep)
+			continue
+		}
+		if keep(field) && dv.CanSet() {
+			dv.Set(sv)
+		}
+	}
+}
+
+// isJSONField ... true for a field of the JSON body read by Bind
+func isJSONField(field reflect.StructField) bool {
+	name, ok := field.Tag.Lookup("json")
+	return ok && name != "-"
+}
+
+// isBodyField ... true for a field that is not bound from the path, the query, a header or a cookie
+func isBodyField(field reflect.StructField) bool {
+	for _, source := range bindSources {
+		if _, ok := field.Tag.Lookup(source); ok && source != "form" {
+			return false
+		}
+	}
+	return true
+}
+
+// setField ... converts the values into the field, a pointer is allocated and a slice gets every value
+func (b *binder) setField(fv reflect.Value, field reflect.StructField, source string, name string, values []string) error {
+	if len(values) == 0 {
+		return nil
+	}
+	target := fv
+	if fv.Kind() == reflect.Pointer {
+		target = reflect.New(fv.Type().Elem()).Elem()
+	}
+	layout := field.Tag.Get("layout")
+	if target.Kind() == reflect.Slice && target.Type().Elem().Kind() != reflect.Uint8 {
+		slice := reflect.MakeSlice(target.Type(), len(values), len(values))
+		for i, s := range values {
+			ok, err := setValue(slice.Index(i), s, layout)
+			if err != nil {
+				return fmt.Errorf("%s: field %s: %w", InvalidBindTarget, field.Name, err)
+			}
+			if !ok {
+				b.errs = append(b.errs, fieldError(slice.Index(i).Type(), source, name, s))
+				return nil
+			}
+		}
+		target.Set(slice)
+	} else {
+		ok, err := setValue(target, values[0], layout)
+		if err != nil {
+			return fmt.Errorf("%s: field %s: %w", InvalidBindTarget, field.Name, err)
+		}
+		if !ok {
+			b.errs = append(b.errs, fieldError(target.Type(), source, name, values[0]))
+			return nil
+		}
+	}
+	if fv.Kind() == reflect.Pointer {
+		fv.Set(target.Addr())
+	}
+	return nil
+}
+
+// setValue ... converts s into v, ok is false if s does not convert and err is set if the type is not supported
+func setValue(v reflect.Value, s string, layout string) (ok bool, err error) {
+	if v.Type() == timeType && layout != "" {
+		t, err := time.Parse(layout, s)
+		if err != nil {
+			return false, nil
+		}
+		v.Set(reflect.ValueOf(t))
+		return true, nil
+	}
+	if reflect.PointerTo(v.Type()).Implements(textUnmarshalType) {
+		return v.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(s)) == nil, nil
+	}
+	if v.Type() == durationType {
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return false, nil
+		}
+		v.SetInt(int64(d))
+		return true, nil
+	}
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Slice:
+		//a []byte gets the raw value
+		v.SetBytes([]byte(s))
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return false, nil
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(s, 10, v.Type().Bits())
+		if err != nil {
+			return false, nil
+		}
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(s, 10, v.Type().Bits())
+		if err != nil {
+			return false, nil
+		}
+		v.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(s, v.Type().Bits())
+		if err != nil {
+			return false, nil
+		}
+		v.SetFloat(f)
+	default:
+		return false, fmt.Errorf("unsupported type %s", v.Type())
+	}
+	return true, nil
+}
+
+// fieldError ... the error for a value that does not convert to the type
+func fieldError(t reflect.Type, source string, name string, value string) FieldError {
+	return FieldError{Field: name, Source: source, Value: value, Message: "expected " + typeName(t)}
+}
+
+// typeName ... the type as the client would describe it
+func typeName(t reflect.Type) string {
+	switch {
+	case t == timeType:
+		return "a time"
+	case t == durationType:
+		return "a duration"
+	case t == reflect.TypeOf(UUID{}):
+		return "a UUID"
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		return "a boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return "an integer"
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "a positive integer"
+	case reflect.Float32, reflect.Float64:
+		return "a number"
+	case reflect.String:
+		return "a string"
+	case reflect.Slice, reflect.Array:
+		return "a list"
+	case reflect.Map, reflect.Struct:
+		return "an object"
+	}
+	return t.String()
+}
+
+// defaultValues ... the default tag, split on commas for a slice field
+func defaultValues(fv reflect.Value, def string) []string {
+	t := fv.Type()
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t.Kind() == reflect.Slice && t.Elem().Kind() != reflect.Uint8 {
+		return strings.Split(def, ",")
+	}
+	return []string{def}
+}
+
+// jsonName ... the name of the field in the JSON body
+func jsonName(field reflect.StructField, tag string) string {
+	if name, _, _ := strings.Cut(tag, ","); name != "" {
+		return name
+	}
+	return field.Name
+}
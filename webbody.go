@@ -0,0 +1,233 @@
+package gweb
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// BodyDecoder ... decodes the body of the request into v
+type BodyDecoder func(r *http.Request, v any) error
+
+// RegisterDecoder ... adds the decoder used by ParseBody for a media type like application/msgpack
+// it replaces the builtin decoder for the media type
+func (w *Web) RegisterDecoder(mediaType string, decoder BodyDecoder) error {
+	parsed, _, err := mime.ParseMediaType(mediaType)
+	if err != nil || decoder == nil {
+		return fmt.Errorf("%s: %q", InvalidDecoder, mediaType)
+	}
+	if w.decoders == nil {
+		w.decoders = make(map[string]BodyDecoder)
+	}
+	w.decoders[parsed] = decoder
+	return nil
+}
+
+// decoderFor ... the registered or builtin decoder for the media type, nil if there is none
+func (wc *WebContext) decoderFor(mediaType string) BodyDecoder {
+	if wc.web != nil {
+		if decoder, ok := wc.web.decoders[mediaType]; ok {
+			return decoder
+		}
+	}
+	switch {
+	case mediaType == "application/json" || strings.HasSuffix(mediaType, "+json"):
+		return wc.decodeJSON
+	case mediaType == "application/xml" || mediaType == "text/xml" || strings.HasSuffix(mediaType, "+xml"):
+		return decodeXML
+	case mediaType == "application/x-www-form-urlencoded" || mediaType == "multipart/form-data":
+		return wc.decodeForm
+	}
+	return nil
+}
+
+// JSONOptions ... how the JSON bodies are decoded by ParseBody and Bind
+type JSONOptions struct {
+	//the largest body read, a larger one is a 413, 0 for no limit
+	MaxBytes int64
+	//a field of the body that is not in the struct is a 400
+	DisallowUnknownFields bool
+	//anything but white space after the first JSON value is a 400
+	SingleValue bool
+	//numbers decoded into an interface are json.Number instead of float64
+	UseNumber bool
+}
+
+// UseJSONOptions ... a middleware that sets how ParseBody and Bind decode the JSON body for the rest of the chain
+// it replaces the options of the Web for a route or a group
+func UseJSONOptions(opts JSONOptions) WebHandler {
+	return func(wc *WebContext) error {
+		wc.jsonOptions = &opts
+		return wc.Next()
+	}
+}
+
+// errTrailingData ... the body has more than one JSON value
+var errTrailingData = errors.New("unexpected data after the JSON value")
+
+// decodeJSON ... decodes the JSON body with the options of the route
+// every failure is an HTTPError, the code tells them apart
+func (wc *WebContext) decodeJSON(r *http.Request, v any) error {
+	var opts JSONOptions
+	if wc.jsonOptions != nil {
+		opts = *wc.jsonOptions
+	} else if wc.web != nil && wc.web.jsonOptions != nil {
+		opts = *wc.web.jsonOptions
+	}
+	body := r.Body
+	if opts.MaxBytes > 0 {
+		body = http.MaxBytesReader(wc.Writer, body, opts.MaxBytes)
+	}
+	decoder := json.NewDecoder(body)
+	if opts.DisallowUnknownFields {
+		decoder.DisallowUnknownFields()
+	}
+	if opts.UseNumber {
+		decoder.UseNumber()
+	}
+	err := decoder.Decode(v)
+	if err == nil && opts.SingleValue {
+		if _, err = decoder.Token(); err == io.EOF {
+			err = nil
+		} else if !errors.As(err, new(*http.MaxBytesError)) {
+			err = errTrailingData
+		}
+	}
+	return jsonError(err)
+}
+
+// jsonError ... maps an error of the JSON decoder to an HTTPError
+func jsonError(err error) error {
+	if err == nil {
+		return nil
+	}
+	var maxErr *http.MaxBytesError
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case errors.As(err, &maxErr):
+		return NewHTTPError(http.StatusRequestEntityTooLarge, fmt.Sprintf("body larger than %d bytes", maxErr.Limit)).
+			WithCode("body_too_large").Wrap(err)
+	case errors.Is(err, io.EOF):
+		return NewHTTPError(http.StatusBadRequest, "empty body").WithCode("empty_body").Wrap(err)
+	case errors.Is(err, errTrailingData):
+		return NewHTTPError(http.StatusBadRequest, err.Error()).WithCode("trailing_data")
+	case errors.As(err, &typeErr):
+		fe := FieldError{Field: typeErr.Field, Source: "json", Value: typeErr.Value, Message: "expected " + typeName(typeErr.Type)}
+		return NewHTTPError(http.StatusBadRequest, "invalid request, "+fe.Error()).
+			WithCode("invalid_request").WithDetails([]FieldError{fe}).Wrap(err)
+	case strings.HasPrefix(err.Error(), "json: unknown field "):
+		//the decoder has no error type for an unknown field
+		name, _ := strconv.Unquote(strings.TrimPrefix(err.Error(), "json: unknown field "))
+		fe := FieldError{Field: name, Source: "json", Message: "unknown field"}
+		return NewHTTPError(http.StatusBadRequest, "invalid request, "+fe.Error()).
+			WithCode("unknown_field").WithDetails([]FieldError{fe}).Wrap(err)
+	}
+	return NewHTTPError(http.StatusBadRequest, "invalid JSON body").WithCode("invalid_json").Wrap(err)
+}
+
+func decodeXML(r *http.Request, v any) error {
+	return xml.NewDecoder(r.Body).Decode(v)
+}
+
+// parseMultipartForm ... parses the form of the request, the temp files of a multipart form are removed at the end of the request
+// net/http only removes them for the request the server created, not for a copy made by Set or WithContext
+func (wc *WebContext) parseMultipartForm(r *http.Request) error {
+	parsed := r.MultipartForm != nil
+	err := r.ParseMultipartForm(defaultMultipartMemory)
+	if form := r.MultipartForm; !parsed && form != nil {
+		wc.cleanups = append(wc.cleanups, func() {
+			form.RemoveAll()
+		})
+	}
+	return err
+}
+
+// decodeForm ... fills a struct from an urlencoded or multipart form, a *url.Values gets the whole form
+// the field names come from the form tag, then the json tag, then the name of the field
+func (wc *WebContext) decodeForm(r *http.Request, v any) error {
+	err := wc.parseMultipartForm(r)
+	if err != nil && !errors.Is(err, http.ErrNotMultipart) {
+		return err
+	}
+	if values, ok := v.(*url.Values); ok {
+		*values = r.PostForm
+		return nil
+	}
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("%s: %T", InvalidBindTarget, v)
+	}
+	b := binder{}
+	if err := b.bindForm(rv.Elem(), r.PostForm); err != nil {
+		return err
+	}
+	return b.err()
+}
+
+// bindForm ... binds the fields of a struct from the form values
+func (b *binder) bindForm(rv reflect.Value, form url.Values) error {
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		field := rt.Field(i)
+		fv := rv.Field(i)
+		if field.Anonymous && isStruct(field.Type) {
+			if fv.Kind() == reflect.Pointer {
+				if fv.IsNil() {
+					if !fv.CanSet() {
+						continue
+					}
+					fv.Set(reflect.New(field.Type.Elem()))
+				}
+				fv = fv.Elem()
+			}
+			if err := b.bindForm(fv, form); err != nil {
+				return err
+			}
+			continue
+		}
+		if !field.IsExported() {
+			continue
+		}
+		name := formName(field)
+		if name == "" {
+			continue
+		}
+		values := form[name]
+		if len(values) == 0 {
+			def, ok := field.Tag.Lookup("default")
+			if !ok {
+				continue
+			}
+			values = defaultValues(fv, def)
+		}
+		if err := b.setField(fv, field, "form", name, values); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// formName ... the name of the field in a form, empty if it is skipped
+func formName(field reflect.StructField) string {
+	if tag, ok := field.Tag.Lookup("form"); ok {
+		if tag == "-" {
+			return ""
+		}
+		return tag
+	}
+	if tag, ok := field.Tag.Lookup("json"); ok {
+		if tag == "-" {
+			return ""
+		}
+		return jsonName(field, tag)
+	}
+	return field.Name
+}
@@ -12,3 +12,33 @@ const WebNotInitialized = "Web Instance not initialized yet"
 const InvalidWebGroup = "Invalid web group"
 const InvalidPath = "Invalid path, mising /"
 const NoWebSocket = "No active websocket connection"
+const InvalidLogger = "Invalid logger"
+const InvalidCertificate = "Invalid certificate or key file"
+const InvalidTLSConfig = "Invalid TLS config"
+const InvalidTimeout = "Invalid timeout, must not be negative"
+const InvalidMaxHeaderBytes = "Invalid max header bytes, must be greater than 0"
+const InvalidContextFunc = "Invalid context function"
+const InvalidListener = "Invalid listener"
+const InvalidSocketMode = "Invalid socket mode, only permission bits are allowed"
+const NotASocket = "File exists and is not a socket"
+const SocketInUse = "Socket is already in use"
+const NoSystemdListeners = "No listeners passed by systemd"
+const InvalidPattern = "Invalid route pattern"
+const InvalidErrorHandler = "Invalid error handler"
+const InvalidMethod = "Invalid HTTP method"
+const InvalidRouteName = "Invalid route name"
+const UnknownRoute = "Unknown route name"
+const InvalidRouteParams = "Invalid route parameters"
+const InvalidUUID = "Invalid UUID"
+const InvalidBindTarget = "Bind needs a pointer to a struct"
+const InvalidValidation = "Invalid validation rule"
+const UnknownValidation = "Unknown validation rule"
+const InvalidValidateTarget = "Validate needs a struct or a pointer to a struct"
+const InvalidDecoder = "Invalid body decoder"
+const InvalidMaxBytes = "Invalid max bytes, must not be negative"
+const InvalidUploadLimits = "Invalid upload limits, must not be negative"
+const UploadNotOnDisk = "Upload was streamed and is not on disk"
+const UploadNotFound = "Upload not found"
+const InvalidUploadID = "Invalid upload id"
+const InvalidTusConfig = "Invalid tus config, a store is required and the limits must not be negative"
+const InvalidEncoder = "Invalid body encoder"
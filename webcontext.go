@@ -6,10 +6,30 @@ import (
 	"errors"
 	"html/template"
 	"io"
+	"mime"
 	"net/http"
 	"strings"
 )
 
+// Next ... runs the next middleware or the handler in the chain and returns its error
+// code after Next runs once the rest of the chain has finished, so a middleware can inspect the status,
+// time the handler or handle the error. Returning without calling Next stops the chain
+func (wc *WebContext) Next() error {
+	wc.index++
+	if wc.index >= len(wc.handlers) {
+		return nil
+	}
+	return wc.handlers[wc.index](wc)
+}
+
+// cleanup ... runs the cleanups of the request, the last added runs first
+func (wc *WebContext) cleanup() {
+	for i := len(wc.cleanups) - 1; i >= 0; i-- {
+		wc.cleanups[i]()
+	}
+	wc.cleanups = nil
+}
+
 // get the query parameter
 func (wc *WebContext) GetParam(key string) string {
 
@@ -26,29 +46,73 @@ func (wc *WebContext) GetPathValue(key string) string {
 
 // SendStatus ... send the stataus to the user
 // is you pass  < 200 status it will be automatically set as 200
+// the status is sent with the first write of the response
 func (wc *WebContext) Status(status int) *WebContext {
 	if status < 200 {
 		status = http.StatusOK
 	}
 	wc.ReplyStatus = status
+	wc.Writer.SetStatus(status)
 	return wc
 }
 
-// SendError ... sends the error passed as a response with the ReplyStatus set
+// SendError ... sends the error as a response using the ErrorHandler of the Web
+// the status comes from an HTTPError, any other error is a 500
+// if the response has already started the error is only logged
 func (wc *WebContext) SendError(err error) {
-	wc.ReplyStatus = http.StatusInternalServerError
-	http.Error(wc.Writer, err.Error(), wc.ReplyStatus)
+	if err == nil {
+		return
+	}
+	if status, _ := errorStatus(err); status >= http.StatusInternalServerError {
+		wc.WebLog.Error("request failed", "WebErr", err)
+	}
+	if wc.Writer.Written() {
+		wc.WebLog.Error("response already started, error not sent", "WebErr", err)
+		return
+	}
+	if bw, ok := wc.Writer.(*BufferedWriter); ok {
+		//replace the partial response held by BufferResponse
+		bw.Reset()
+	}
+	handler := DefaultErrorHandler
+	if wc.web != nil && wc.web.ErrorHandler != nil {
+		handler = wc.web.ErrorHandler
+	}
+	handler(wc, err)
 }
 
-// ParseBody .. parse the request body
+// ParseBody .. parse the request body with the decoder for its Content-Type
+// JSON is used when there is no Content-Type, forms fill the fields by their form tag
+// a body that does not decode is a 400 HTTPError and a media type without a decoder is a 415
 func (wc *WebContext) ParseBody(data any) error {
 	if data == nil {
 
 		return errors.New(InvalidData)
 	}
-
-	decoder := json.NewDecoder(wc.Request.Body)
-	return decoder.Decode(data)
+	contentType := wc.Request.Header.Get("Content-Type")
+	mediaType := "application/json"
+	if contentType != "" {
+		var err error
+		mediaType, _, err = mime.ParseMediaType(contentType)
+		if err != nil {
+			return NewHTTPError(http.StatusUnsupportedMediaType, "invalid Content-Type").
+				WithCode("unsupported_media_type").Wrap(err)
+		}
+	}
+	decoder := wc.decoderFor(mediaType)
+	if decoder == nil {
+		return NewHTTPError(http.StatusUnsupportedMediaType, "unsupported Content-Type "+mediaType).
+			WithCode("unsupported_media_type")
+	}
+	err := decoder(wc.Request, data)
+	if err == nil {
+		return nil
+	}
+	if _, httpErr := errorStatus(err); httpErr != nil {
+		return err
+	}
+	return NewHTTPError(http.StatusBadRequest, "invalid "+mediaType+" body").
+		WithCode("invalid_body").Wrap(err)
 }
 
 // JSON ... send the data as a JSON object
@@ -64,12 +128,11 @@ func (wc *WebContext) JSON(data any) error {
 	if wc.ReplyStatus == 0 {
 		wc.ReplyStatus = http.StatusOK
 	}
-	//wc.Writer.WriteHeader(wc.ReplyStatus)
 	err := encoder.Encode(data)
 	if err != nil {
 		wc.WebLog.Error("sending json", "WebErr", err)
 	}
-	return nil
+	return err
 }
 
 // SendString ... send the text data
@@ -87,7 +150,6 @@ func (wc *WebContext) SendString(data *strings.Reader, contentType ...string) er
 	if wc.ReplyStatus == 0 {
 		wc.ReplyStatus = http.StatusOK
 	}
-	//wc.Writer.WriteHeader(wc.ReplyStatus)
 	var err error
 
 	_, err = io.Copy(wc.Writer, data)
@@ -108,7 +170,6 @@ func (wc *WebContext) SendBytes(data *bytes.Reader) error {
 	if wc.ReplyStatus == 0 {
 		wc.ReplyStatus = http.StatusOK
 	}
-	//wc.Writer.WriteHeader(wc.ReplyStatus)
 	var err error
 
 	_, err = io.Copy(wc.Writer, data)
@@ -134,12 +195,16 @@ func (wc *WebContext) RenderString(data *strings.Reader) error {
 // data provide the Data that needs to be passed to the head file
 // headFile is the file that is the start of the view for example index.html
 // funcMap ... pass any function map that needs to be passed, it is optional
+// the templates can build the path of a named route with urlFor, for example {{ urlFor "user" "id" .ID }}
 func (wc *WebContext) RenderFiles(filePattern string, data any, headFile string, funcMap template.FuncMap) error {
 	if data == nil {
 
 		return errors.New(InvalidData)
 	}
 	templ := template.New("new")
+	if wc.web != nil {
+		templ = templ.Funcs(template.FuncMap{"urlFor": wc.web.URLFor})
+	}
 	var err error
 	if funcMap != nil {
 		templ, err = templ.Funcs(funcMap).ParseGlob(filePattern)
@@ -158,5 +223,5 @@ func (wc *WebContext) RenderFiles(filePattern string, data any, headFile string,
 	if err != nil {
 		wc.WebLog.Error("executing template", "WebErr", err)
 	}
-	return nil
+	return err
 }
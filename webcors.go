@@ -0,0 +1,173 @@
+package gweb
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CorsConfig ... the CORS settings for the Web or a WebGroup
+type CorsConfig struct {
+	// AllowOrigins ... the allowed origins, an exact origin like "https://example.com",
+	// a wildcard subdomain like "https://*.example.com" or "*" for any origin
+	AllowOrigins []string
+	// AllowOriginFunc ... called for origins not matched by AllowOrigins, optional
+	AllowOriginFunc func(origin string) bool
+	// AllowMethods ... the methods allowed in a preflight, all the methods of the path if empty
+	AllowMethods []string
+	// AllowHeaders ... the request headers allowed in a preflight, the requested ones if empty
+	AllowHeaders []string
+	// ExposeHeaders ... the response headers the browser can read
+	ExposeHeaders []string
+	// AllowCredentials ... allow cookies and authorization, the origin is echoed instead of *
+	AllowCredentials bool
+	// MaxAge ... how long the browser can cache a preflight, not sent if 0
+	MaxAge time.Duration
+}
+
+// DefaultCorsConfig ... any origin with the common methods and headers
+func DefaultCorsConfig() CorsConfig {
+	return CorsConfig{
+		AllowOrigins: []string{"*"},
+		AllowMethods: []string{http.MethodGet, http.MethodPost, http.MethodPut, http.MethodDelete, http.MethodOptions},
+		AllowHeaders: []string{"Content-Type", "Authorization"},
+	}
+}
+
+// allowOrigin ... true if the origin is allowed, any is true if all origins are allowed
+func (c *CorsConfig) allowOrigin(origin string) (allowed bool, any bool) {
+	for _, o := range c.AllowOrigins {
+		if o == "*" {
+			return true, true
+		}
+		if origin == "" {
+			continue
+		}
+		if strings.EqualFold(o, origin) {
+			return true, false
+		}
+		//https://*.example.com matches https://api.example.com but not https://example.com
+		if scheme, host, ok := strings.Cut(o, "*."); ok && strings.HasPrefix(origin, scheme) {
+			sub := strings.TrimPrefix(origin, scheme)
+			if strings.HasSuffix(strings.ToLower(sub), "."+strings.ToLower(host)) {
+				return true, false
+			}
+		}
+	}
+	if origin != "" && c.AllowOriginFunc != nil && c.AllowOriginFunc(origin) {
+		return true, false
+	}
+	return false, false
+}
+
+// isPreflight ... true for a CORS preflight request
+func isPreflight(r *http.Request) bool {
+	return r.Method == http.MethodOptions && r.Header.Get("Origin") != "" &&
+		r.Header.Get("Access-Control-Request-Method") != ""
+}
+
+// middlewareCors ... sets the CORS headers for the request
+// allowedMethods ... the methods registered for the path, used for a preflight when AllowMethods is empty
+// returns true if the request is a preflight for a registered path that should be answered without running the handlers
+func middlewareCors(ctx *WebContext, c *CorsConfig, allowedMethods func(*http.Request) []string) bool {
+	if ctx == nil || ctx.Writer == nil || ctx.Request == nil || c == nil {
+		return false
+	}
+	h := ctx.Writer.Header()
+	origin := ctx.Request.Header.Get("Origin")
+	allowed, any := c.allowOrigin(origin)
+	if !any || c.AllowCredentials {
+		//the response depends on the origin, caches must not share it
+		h.Add("Vary", "Origin")
+	}
+	if !allowed {
+		return false
+	}
+	if any && !c.AllowCredentials {
+		h.Set("Access-Control-Allow-Origin", "*")
+	} else if origin != "" {
+		h.Set("Access-Control-Allow-Origin", origin)
+	} else {
+		return false
+	}
+	if c.AllowCredentials {
+		h.Set("Access-Control-Allow-Credentials", "true")
+	}
+
+	if !isPreflight(ctx.Request) {
+		if len(c.ExposeHeaders) > 0 {
+			h.Set("Access-Control-Expose-Headers", strings.Join(c.ExposeHeaders, ", "))
+		}
+		return false
+	}
+	registered := allowedMethods(ctx.Request)
+	if len(registered) == 0 {
+		//only the registered paths are answered, any other gets the normal 404
+		return false
+	}
+	h.Add("Vary", "Access-Control-Request-Method")
+	h.Add("Vary", "Access-Control-Request-Headers")
+	methods := c.AllowMethods
+	if len(methods) == 0 {
+		methods = registered
+	}
+	if len(methods) > 0 {
+		h.Set("Access-Control-Allow-Methods", strings.Join(methods, ", "))
+	}
+	if len(c.AllowHeaders) > 0 {
+		h.Set("Access-Control-Allow-Headers", strings.Join(c.AllowHeaders, ", "))
+	} else if requested := ctx.Request.Header.Get("Access-Control-Request-Headers"); requested != "" {
+		h.Set("Access-Control-Allow-Headers", requested)
+	}
+	if c.MaxAge > 0 {
+		h.Set("Access-Control-Max-Age", strconv.Itoa(int(c.MaxAge.Seconds())))
+	}
+	return true
+}
+
+// WithCors ... apply the CORS config to all the routes
+func (w *Web) WithCors(c CorsConfig) *Web {
+	w.cors = &c
+	return w
+}
+
+// enable default cors
+func (w *Web) WithDefaultCors() *Web {
+	return w.WithCors(DefaultCorsConfig())
+}
+
+// apply CORS with custom headers and methods for any origin
+func (w *Web) WithCustomCors(headers []string, methods []string) *Web {
+	return w.WithCors(CorsConfig{
+		AllowOrigins: []string{"*"},
+		AllowHeaders: headers,
+		AllowMethods: methods,
+	})
+}
+
+// WithCors ... apply the CORS config to the routes of the group and its subgroups
+func (wg *WebGroup) WithCors(c CorsConfig) *WebGroup {
+	wg.cors = &corsSettings{config: &c}
+	return wg
+}
+
+// WithDefaultCors ... enable default cors for the routes of the group and its subgroups
+func (wg *WebGroup) WithDefaultCors() *WebGroup {
+	return wg.WithCors(DefaultCorsConfig())
+}
+
+// WithCustomCors ... apply CORS with custom headers and methods for the routes of the group and its subgroups
+func (wg *WebGroup) WithCustomCors(headers []string, methods []string) *WebGroup {
+	return wg.WithCors(CorsConfig{
+		AllowOrigins: []string{"*"},
+		AllowHeaders: headers,
+		AllowMethods: methods,
+	})
+}
+
+// WithoutCors ... disable cors for the routes of the group and its subgroups
+func (wg *WebGroup) WithoutCors() *WebGroup {
+	wg.cors = &corsSettings{}
+	return wg
+}
@@ -0,0 +1,123 @@
+package gweb
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+)
+
+// ErrorHandler ... writes the response for an error returned by a middleware or a handler
+type ErrorHandler func(wc *WebContext, err error)
+
+// HTTPError ... an error that is sent to the client with its status code
+// return it from a middleware or a handler and the error handler maps it to the response
+type HTTPError struct {
+	//the HTTP status code to reply with
+	Status int `json:"status"`
+	//an application specific error code, optional
+	Code string `json:"code,omitempty"`
+	//the message for the client
+	Message string `json:"message"`
+	//any extra data for the client, it is encoded as JSON by ProblemJSONErrorHandler
+	Details any `json:"details,omitempty"`
+	//the cause, it is not sent to the client
+	Err error `json:"-"`
+}
+
+// NewHTTPError ... creates an error with the status and message
+// if message is empty the status text is used
+func NewHTTPError(status int, message string) *HTTPError {
+	if message == "" {
+		message = http.StatusText(status)
+	}
+	return &HTTPError{Status: status, Message: message}
+}
+
+// Error ... the message followed by the cause if there is one
+func (e *HTTPError) Error() string {
+	if e.Err != nil {
+		return e.Message + ": " + e.Err.Error()
+	}
+	return e.Message
+}
+
+// Unwrap ... the cause of the error
+func (e *HTTPError) Unwrap() error {
+	return e.Err
+}
+
+// WithCode ... set the application specific error code
+func (e *HTTPError) WithCode(code string) *HTTPError {
+	e.Code = code
+	return e
+}
+
+// WithDetails ... set extra data for the client
+func (e *HTTPError) WithDetails(details any) *HTTPError {
+	e.Details = details
+	return e
+}
+
+// Wrap ... set the cause of the error
+func (e *HTTPError) Wrap(err error) *HTTPError {
+	e.Err = err
+	return e
+}
+
+// errorStatus ... the status of the HTTPError in the chain of err, 500 for any other error
+func errorStatus(err error) (int, *HTTPError) {
+	var httpErr *HTTPError
+	if errors.As(err, &httpErr) && httpErr.Status >= 400 {
+		return httpErr.Status, httpErr
+	}
+	return http.StatusInternalServerError, nil
+}
+
+// DefaultErrorHandler ... replies with the error message as plain text
+// the status is taken from an HTTPError, any other error is a 500
+func DefaultErrorHandler(wc *WebContext, err error) {
+	status, httpErr := errorStatus(err)
+	message := err.Error()
+	if httpErr != nil {
+		message = httpErr.Message
+	}
+	wc.ReplyStatus = status
+	http.Error(wc.Writer, message, status)
+}
+
+// problem ... an RFC 7807 problem details object
+type problem struct {
+	Type     string `json:"type"`
+	Title    string `json:"title"`
+	Status   int    `json:"status"`
+	Detail   string `json:"detail,omitempty"`
+	Instance string `json:"instance,omitempty"`
+	Code     string `json:"code,omitempty"`
+	Details  any    `json:"details,omitempty"`
+}
+
+// ProblemJSONErrorHandler ... replies with an RFC 7807 application/problem+json body
+// only the message, code and details of an HTTPError are sent, other errors only get the status title
+func ProblemJSONErrorHandler(wc *WebContext, err error) {
+	status, httpErr := errorStatus(err)
+	p := problem{
+		Type:   "about:blank",
+		Title:  http.StatusText(status),
+		Status: status,
+	}
+	if wc.Request != nil {
+		p.Instance = wc.Request.URL.Path
+	}
+	if httpErr != nil {
+		p.Detail = httpErr.Message
+		p.Code = httpErr.Code
+		p.Details = httpErr.Details
+	}
+	wc.ReplyStatus = status
+	wc.Writer.Header().Set("Content-Type", "application/problem+json")
+	wc.Writer.Header().Set("X-Content-Type-Options", "nosniff")
+	wc.Writer.WriteHeader(status)
+	if err := json.NewEncoder(wc.Writer).Encode(p); err != nil {
+		wc.WebLog.Error("sending problem json", "WebErr", err)
+	}
+}
@@ -6,6 +6,65 @@ import (
 	"strings"
 )
 
+// ServeHTTP ... makes WebGroup a http.Handler serving only the routes of the group and its subgroups
+// the global middlewares, cors and logging apply the same way as with Run
+func (wg *WebGroup) ServeHTTP(wr http.ResponseWriter, r *http.Request) {
+	_, pattern := wg.w.router.Handler(r)
+	owner := wg.w.routes[pattern]
+	if pattern == fallbackPattern {
+		//a 405 or a preflight for a path of the group
+		owner = wg.w.groupFor(r)
+	}
+	//an empty pattern is a redirect to the canonical path
+	if pattern != "" && !wg.contains(owner) {
+		wg.w.serveNotFound(wr, r, wg)
+		return
+	}
+	wg.w.router.ServeHTTP(wr, r)
+}
+
+// Group ... creates a subgroup, the prefix is appended to the prefix of wg
+// the subgroup inherits the middlewares, cors and logging settings of wg
+func (wg *WebGroup) Group(prefix string) (*WebGroup, error) {
+	return wg.w.newGroup(prefix, wg)
+}
+
+// WithLogging ... enable logging for the routes of the group and its subgroups
+func (wg *WebGroup) WithLogging() *WebGroup {
+	enabled := true
+	wg.logging = &enabled
+	return wg
+}
+
+// WithoutLogging ... disable logging for the routes of the group and its subgroups
+func (wg *WebGroup) WithoutLogging() *WebGroup {
+	enabled := false
+	wg.logging = &enabled
+	return wg
+}
+
+// NotFound ... the handler for the requests under the group prefix no route matched
+// it runs after the global and group middlewares, the default is the NotFound handler of the parent
+func (wg *WebGroup) NotFound(f WebHandler) {
+	wg.notFound = f
+}
+
+// MethodNotAllowed ... the handler for a path of the group registered with other methods
+// it runs after the global and group middlewares, the default is the MethodNotAllowed handler of the parent
+func (wg *WebGroup) MethodNotAllowed(f WebHandler) {
+	wg.methodNotAllowed = f
+}
+
+// contains ... true if g is wg or one of its subgroups
+func (wg *WebGroup) contains(g *WebGroup) bool {
+	for ; g != nil; g = g.parent {
+		if g == wg {
+			return true
+		}
+	}
+	return false
+}
+
 // Use ... add a middleware for the Group
 func (wg *WebGroup) Use(f WebHandler) {
 	if f == nil {
@@ -14,7 +73,21 @@ func (wg *WebGroup) Use(f WebHandler) {
 	}
 	wg.middlewares = append(wg.middlewares, f)
 }
-func (wg *WebGroup) Get(pattern string, f WebHandler) error {
+
+// Get ... adds a GET handler under the group prefix
+// middlewares ... optional middlewares only for this route, they run after the global and group middlewares
+func (wg *WebGroup) Get(pattern string, f WebHandler, middlewares ...WebHandler) error {
+	if f == nil {
+
+		return errors.New(InvalidData)
+	}
+	if !strings.HasPrefix(pattern, "/") {
+		return errors.New(InvalidPath)
+	}
+	return wg.w.addRoutes(http.MethodGet+" "+wg.prefix+pattern, f, wg, middlewares...)
+}
+
+func (wg *WebGroup) Post(pattern string, f WebHandler, middlewares ...WebHandler) error {
 	if f == nil {
 
 		return errors.New(InvalidData)
@@ -22,11 +95,10 @@ func (wg *WebGroup) Get(pattern string, f WebHandler) error {
 	if !strings.HasPrefix(pattern, "/") {
 		return errors.New(InvalidPath)
 	}
-	wg.w.addRoutes(http.MethodGet+" "+wg.pattern+pattern, f, wg)
-	return nil
+	return wg.w.addRoutes(http.MethodPost+" "+wg.prefix+pattern, f, wg, middlewares...)
 }
 
-func (wg *WebGroup) Post(pattern string, f WebHandler) error {
+func (wg *WebGroup) Put(pattern string, f WebHandler, middlewares ...WebHandler) error {
 	if f == nil {
 
 		return errors.New(InvalidData)
@@ -34,11 +106,10 @@ func (wg *WebGroup) Post(pattern string, f WebHandler) error {
 	if !strings.HasPrefix(pattern, "/") {
 		return errors.New(InvalidPath)
 	}
-	wg.w.addRoutes(http.MethodPost+" "+wg.pattern+pattern, f, wg)
-	return nil
+	return wg.w.addRoutes(http.MethodPut+" "+wg.prefix+pattern, f, wg, middlewares...)
 }
 
-func (wg *WebGroup) Put(pattern string, f WebHandler) error {
+func (wg *WebGroup) Patch(pattern string, f WebHandler, middlewares ...WebHandler) error {
 	if f == nil {
 
 		return errors.New(InvalidData)
@@ -46,11 +117,10 @@ func (wg *WebGroup) Put(pattern string, f WebHandler) error {
 	if !strings.HasPrefix(pattern, "/") {
 		return errors.New(InvalidPath)
 	}
-	wg.w.addRoutes(http.MethodPut+" "+wg.pattern+pattern, f, wg)
-	return nil
+	return wg.w.addRoutes(http.MethodPatch+" "+wg.prefix+pattern, f, wg, middlewares...)
 }
 
-func (wg *WebGroup) Patch(pattern string, f WebHandler) error {
+func (wg *WebGroup) Delete(pattern string, f WebHandler, middlewares ...WebHandler) error {
 	if f == nil {
 
 		return errors.New(InvalidData)
@@ -58,11 +128,10 @@ func (wg *WebGroup) Patch(pattern string, f WebHandler) error {
 	if !strings.HasPrefix(pattern, "/") {
 		return errors.New(InvalidPath)
 	}
-	wg.w.addRoutes(http.MethodPatch+" "+wg.pattern+pattern, f, wg)
-	return nil
+	return wg.w.addRoutes(http.MethodDelete+" "+wg.prefix+pattern, f, wg, middlewares...)
 }
 
-func (wg *WebGroup) Delete(pattern string, f WebHandler) error {
+func (wg *WebGroup) Options(pattern string, f WebHandler, middlewares ...WebHandler) error {
 	if f == nil {
 
 		return errors.New(InvalidData)
@@ -70,11 +139,11 @@ func (wg *WebGroup) Delete(pattern string, f WebHandler) error {
 	if !strings.HasPrefix(pattern, "/") {
 		return errors.New(InvalidPath)
 	}
-	wg.w.addRoutes(http.MethodDelete+" "+wg.pattern+pattern, f, wg)
-	return nil
+	return wg.w.addRoutes(http.MethodOptions+" "+wg.prefix+pattern, f, wg, middlewares...)
 }
 
-func (wg *WebGroup) Options(pattern string, f WebHandler) error {
+// Any ... adds a handler for all the methods of the pattern under the group prefix
+func (wg *WebGroup) Any(pattern string, f WebHandler, middlewares ...WebHandler) error {
 	if f == nil {
 
 		return errors.New(InvalidData)
@@ -82,6 +151,20 @@ func (wg *WebGroup) Options(pattern string, f WebHandler) error {
 	if !strings.HasPrefix(pattern, "/") {
 		return errors.New(InvalidPath)
 	}
-	wg.w.addRoutes(http.MethodOptions+" "+wg.pattern+pattern, f, wg)
-	return nil
+	return wg.w.addRoutes(wg.prefix+pattern, f, wg, middlewares...)
+}
+
+// Handle ... adds a handler for any method under the group prefix, for example PROPFIND or REPORT
+func (wg *WebGroup) Handle(method string, pattern string, f WebHandler, middlewares ...WebHandler) error {
+	if f == nil {
+
+		return errors.New(InvalidData)
+	}
+	if !strings.HasPrefix(pattern, "/") {
+		return errors.New(InvalidPath)
+	}
+	if err := wg.w.addMethod(method); err != nil {
+		return err
+	}
+	return wg.w.addRoutes(method+" "+wg.prefix+pattern, f, wg, middlewares...)
 }
@@ -0,0 +1,208 @@
+package gweb
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"os"
+	"time"
+)
+
+// WebOption ... an option passed to NewWeb to configure the Web instance
+type WebOption func(w *Web) error
+
+// WithLogger ... use the provided slog logger instead of the default JSON logger on stdout
+func WithLogger(logger *slog.Logger) WebOption {
+	return func(w *Web) error {
+		if logger == nil {
+			return errors.New(InvalidLogger)
+		}
+		w.WebLog = logger
+		return nil
+	}
+}
+
+// WithLogging ... enable global logging for all the routes
+func WithLogging() WebOption {
+	return func(w *Web) error {
+		w.WithLogging()
+		return nil
+	}
+}
+
+// WithErrorHandler ... writes the response for the errors returned by middlewares and handlers
+// for example ProblemJSONErrorHandler, the default is DefaultErrorHandler
+func WithErrorHandler(h ErrorHandler) WebOption {
+	return func(w *Web) error {
+		if h == nil {
+			return errors.New(InvalidErrorHandler)
+		}
+		w.ErrorHandler = h
+		return nil
+	}
+}
+
+// WithJSONOptions ... how ParseBody and Bind decode the JSON bodies of all the routes
+// a route or a group can use other options with the UseJSONOptions middleware
+func WithJSONOptions(opts JSONOptions) WebOption {
+	return func(w *Web) error {
+		if opts.MaxBytes < 0 {
+			return fmt.Errorf("%s: %d", InvalidMaxBytes, opts.MaxBytes)
+		}
+		w.jsonOptions = &opts
+		return nil
+	}
+}
+
+// WithUploadLimits ... the limits of the multipart uploads read by MultipartFiles and StreamFiles on all the routes
+// a route or a group can use other limits with the UseUploadLimits middleware
+func WithUploadLimits(limits UploadLimits) WebOption {
+	return func(w *Web) error {
+		if err := limits.check(); err != nil {
+			return err
+		}
+		w.uploadLimits = &limits
+		return nil
+	}
+}
+
+// WithCors ... apply the CORS config to all the routes
+func WithCors(c CorsConfig) WebOption {
+	return func(w *Web) error {
+		w.WithCors(c)
+		return nil
+	}
+}
+
+// WithDefaultCors ... enable default cors for all the routes
+func WithDefaultCors() WebOption {
+	return func(w *Web) error {
+		w.WithDefaultCors()
+		return nil
+	}
+}
+
+// WithCustomCors ... apply CORS with custom headers and methods for all the routes
+func WithCustomCors(headers []string, methods []string) WebOption {
+	return func(w *Web) error {
+		w.WithCustomCors(headers, methods)
+		return nil
+	}
+}
+
+// WithTLSConfig ... the TLS config used by RunTLS, the certificates are managed by RunTLS
+func WithTLSConfig(cfg *tls.Config) WebOption {
+	return func(w *Web) error {
+		if cfg == nil {
+			return errors.New(InvalidTLSConfig)
+		}
+		w.httpServer.TLSConfig = cfg.Clone()
+		return nil
+	}
+}
+
+// WithClientAuth ... verify client certificates against clientCAs when running with RunTLS
+// auth ... for example tls.RequireAndVerifyClientCert or tls.VerifyClientCertIfGiven
+// the verified identity is available in the handlers with WebContext.PeerIdentity
+func WithClientAuth(clientCAs *x509.CertPool, auth tls.ClientAuthType) WebOption {
+	return func(w *Web) error {
+		if clientCAs == nil && auth >= tls.VerifyClientCertIfGiven {
+			return errors.New(InvalidTLSConfig)
+		}
+		if w.httpServer.TLSConfig == nil {
+			w.httpServer.TLSConfig = &tls.Config{MinVersion: tls.VersionTLS12}
+		}
+		w.httpServer.TLSConfig.ClientCAs = clientCAs
+		w.httpServer.TLSConfig.ClientAuth = auth
+		return nil
+	}
+}
+
+// WithReadTimeout ... maximum duration for reading the entire request including the body, 0 means no timeout
+func WithReadTimeout(d time.Duration) WebOption {
+	return timeoutOption("read timeout", d, func(w *Web) { w.httpServer.ReadTimeout = d })
+}
+
+// WithReadHeaderTimeout ... maximum duration for reading the request headers, 0 means the read timeout is used
+func WithReadHeaderTimeout(d time.Duration) WebOption {
+	return timeoutOption("read header timeout", d, func(w *Web) { w.httpServer.ReadHeaderTimeout = d })
+}
+
+// WithWriteTimeout ... maximum duration before timing out writes of the response, 0 means no timeout
+// use 0 or a large value for long downloads and streaming endpoints
+func WithWriteTimeout(d time.Duration) WebOption {
+	return timeoutOption("write timeout", d, func(w *Web) { w.httpServer.WriteTimeout = d })
+}
+
+// WithIdleTimeout ... maximum time to wait for the next request on a keep-alive connection
+// 0 means the read timeout is used
+func WithIdleTimeout(d time.Duration) WebOption {
+	return timeoutOption("idle timeout", d, func(w *Web) { w.httpServer.IdleTimeout = d })
+}
+
+// WithMaxHeaderBytes ... maximum number of bytes the server reads parsing the request headers
+func WithMaxHeaderBytes(n int) WebOption {
+	return func(w *Web) error {
+		if n <= 0 {
+			return fmt.Errorf("%s: %d", InvalidMaxHeaderBytes, n)
+		}
+		w.httpServer.MaxHeaderBytes = n
+		return nil
+	}
+}
+
+// WithKeepAlives ... enable or disable HTTP keep-alives, they are enabled by default
+func WithKeepAlives(enabled bool) WebOption {
+	return func(w *Web) error {
+		w.httpServer.SetKeepAlivesEnabled(enabled)
+		return nil
+	}
+}
+
+// WithBaseContext ... returns the base context for the incoming requests on a listener
+func WithBaseContext(f func(net.Listener) context.Context) WebOption {
+	return func(w *Web) error {
+		if f == nil {
+			return fmt.Errorf("%s: base context", InvalidContextFunc)
+		}
+		w.httpServer.BaseContext = f
+		return nil
+	}
+}
+
+// WithConnContext ... modifies the context used for a new connection
+func WithConnContext(f func(ctx context.Context, c net.Conn) context.Context) WebOption {
+	return func(w *Web) error {
+		if f == nil {
+			return fmt.Errorf("%s: conn context", InvalidContextFunc)
+		}
+		w.httpServer.ConnContext = f
+		return nil
+	}
+}
+
+// timeoutOption ... validates the duration before applying it
+func timeoutOption(name string, d time.Duration, set func(w *Web)) WebOption {
+	return func(w *Web) error {
+		if d < 0 {
+			return fmt.Errorf("%s: %s %v", InvalidTimeout, name, d)
+		}
+		set(w)
+		return nil
+	}
+}
+
+// WithSocketMode ... permissions of the socket file created by RunUnix, the default is 0660
+func WithSocketMode(mode os.FileMode) WebOption {
+	return func(w *Web) error {
+		if mode == 0 || mode != mode.Perm() {
+			return fmt.Errorf("%s: %v", InvalidSocketMode, mode)
+		}
+		w.socketMode = mode
+		return nil
+	}
+}
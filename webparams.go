@@ -0,0 +1,275 @@
+package gweb
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// UUID ... an RFC 4122 UUID
+type UUID [16]byte
+
+// ParseUUID ... parses a UUID in the xxxxxxxx-xxxx-xxxx-xxxx-xxxxxxxxxxxx form
+func ParseUUID(s string) (UUID, error) {
+	var u UUID
+	if len(s) != 36 || s[8] != '-' || s[13] != '-' || s[18] != '-' || s[23] != '-' {
+		return u, errors.New(InvalidUUID)
+	}
+	b := []byte(s[0:8] + s[9:13] + s[14:18] + s[19:23] + s[24:36])
+	if _, err := hex.Decode(u[:], b); err != nil {
+		return u, errors.New(InvalidUUID)
+	}
+	return u, nil
+}
+
+// String ... the UUID in the xxxxxxxx-xxxx-xxxx-xxxx-xxxxxxxxxxxx form
+func (u UUID) String() string {
+	s := hex.EncodeToString(u[:])
+	return s[0:8] + "-" + s[8:12] + "-" + s[12:16] + "-" + s[16:20] + "-" + s[20:32]
+}
+
+// MarshalText ... encodes the UUID as a string in JSON and XML
+func (u UUID) MarshalText() ([]byte, error) {
+	return []byte(u.String()), nil
+}
+
+// UnmarshalText ... decodes the UUID from a string in JSON and XML
+func (u *UUID) UnmarshalText(b []byte) error {
+	parsed, err := ParseUUID(string(b))
+	if err != nil {
+		return err
+	}
+	*u = parsed
+	return nil
+}
+
+// the source of a parameter in the errors
+const (
+	sourcePath  = "path"
+	sourceQuery = "query"
+)
+
+// paramError ... a 400 naming the parameter that could not be parsed
+func paramError(source string, name string, raw string, expected string, err error) error {
+	message := fmt.Sprintf("invalid %s parameter %q, expected %s", source, name, expected)
+	if raw == "" {
+		message = fmt.Sprintf("missing %s parameter %q", source, name)
+	}
+	return NewHTTPError(http.StatusBadRequest, message).
+		WithCode("invalid_parameter").
+		WithDetails(map[string]string{"parameter": name, "source": source, "value": raw}).
+		Wrap(err)
+}
+
+// parseParam ... parses the raw value of a parameter, an empty value is an error
+func parseParam[T any](source string, name string, raw string, expected string, parse func(string) (T, error)) (T, error) {
+	if raw == "" {
+		var zero T
+		return zero, paramError(source, name, raw, expected, nil)
+	}
+	v, err := parse(raw)
+	if err != nil {
+		var zero T
+		return zero, paramError(source, name, raw, expected, err)
+	}
+	return v, nil
+}
+
+// parseQueryDefault ... parses a query parameter, def is returned if it is missing or empty
+func parseQueryDefault[T any](wc *WebContext, name string, def T, expected string, parse func(string) (T, error)) (T, error) {
+	raw := wc.GetParam(name)
+	if raw == "" {
+		return def, nil
+	}
+	return parseParam(sourceQuery, name, raw, expected, parse)
+}
+
+// parseQuerySlice ... parses every value of a repeated query parameter, nil if it is missing
+func parseQuerySlice[T any](wc *WebContext, name string, expected string, parse func(string) (T, error)) ([]T, error) {
+	if wc.query == nil {
+		wc.query = wc.Request.URL.Query()
+	}
+	raws := wc.query[name]
+	if len(raws) == 0 {
+		return nil, nil
+	}
+	values := make([]T, 0, len(raws))
+	for _, raw := range raws {
+		v, err := parseParam(sourceQuery, name, raw, expected, parse)
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, v)
+	}
+	return values, nil
+}
+
+func parseInt64(s string) (int64, error) {
+	return strconv.ParseInt(s, 10, 64)
+}
+
+func parseUint(s string) (uint, error) {
+	v, err := strconv.ParseUint(s, 10, 0)
+	return uint(v), err
+}
+
+func parseFloat(s string) (float64, error) {
+	return strconv.ParseFloat(s, 64)
+}
+
+func timeParser(layout string) func(string) (time.Time, error) {
+	return func(s string) (time.Time, error) {
+		return time.Parse(layout, s)
+	}
+}
+
+// PathInt ... the path value as an int, a 400 HTTPError if it is not one
+func (wc *WebContext) PathInt(name string) (int, error) {
+	return parseParam(sourcePath, name, wc.GetPathValue(name), "an integer", strconv.Atoi)
+}
+
+// PathInt64 ... the path value as an int64, a 400 HTTPError if it is not one
+func (wc *WebContext) PathInt64(name string) (int64, error) {
+	return parseParam(sourcePath, name, wc.GetPathValue(name), "an integer", parseInt64)
+}
+
+// PathUint ... the path value as a uint, a 400 HTTPError if it is not one
+func (wc *WebContext) PathUint(name string) (uint, error) {
+	return parseParam(sourcePath, name, wc.GetPathValue(name), "a positive integer", parseUint)
+}
+
+// PathFloat ... the path value as a float64, a 400 HTTPError if it is not one
+func (wc *WebContext) PathFloat(name string) (float64, error) {
+	return parseParam(sourcePath, name, wc.GetPathValue(name), "a number", parseFloat)
+}
+
+// PathBool ... the path value as a bool, a 400 HTTPError if it is not one
+func (wc *WebContext) PathBool(name string) (bool, error) {
+	return parseParam(sourcePath, name, wc.GetPathValue(name), "a boolean", strconv.ParseBool)
+}
+
+// PathUUID ... the path value as a UUID, a 400 HTTPError if it is not one
+func (wc *WebContext) PathUUID(name string) (UUID, error) {
+	return parseParam(sourcePath, name, wc.GetPathValue(name), "a UUID", ParseUUID)
+}
+
+// PathTime ... the path value as a time in the layout, a 400 HTTPError if it is not one
+func (wc *WebContext) PathTime(name string, layout string) (time.Time, error) {
+	return parseParam(sourcePath, name, wc.GetPathValue(name), "a time like "+layout, timeParser(layout))
+}
+
+// PathDuration ... the path value as a duration like 1h30m, a 400 HTTPError if it is not one
+func (wc *WebContext) PathDuration(name string) (time.Duration, error) {
+	return parseParam(sourcePath, name, wc.GetPathValue(name), "a duration", time.ParseDuration)
+}
+
+// QueryInt ... the query parameter as an int, a 400 HTTPError if it is missing or not one
+func (wc *WebContext) QueryInt(name string) (int, error) {
+	return parseParam(sourceQuery, name, wc.GetParam(name), "an integer", strconv.Atoi)
+}
+
+// QueryInt64 ... the query parameter as an int64, a 400 HTTPError if it is missing or not one
+func (wc *WebContext) QueryInt64(name string) (int64, error) {
+	return parseParam(sourceQuery, name, wc.GetParam(name), "an integer", parseInt64)
+}
+
+// QueryUint ... the query parameter as a uint, a 400 HTTPError if it is missing or not one
+func (wc *WebContext) QueryUint(name string) (uint, error) {
+	return parseParam(sourceQuery, name, wc.GetParam(name), "a positive integer", parseUint)
+}
+
+// QueryFloat ... the query parameter as a float64, a 400 HTTPError if it is missing or not one
+func (wc *WebContext) QueryFloat(name string) (float64, error) {
+	return parseParam(sourceQuery, name, wc.GetParam(name), "a number", parseFloat)
+}
+
+// QueryBool ... the query parameter as a bool, a 400 HTTPError if it is missing or not one
+func (wc *WebContext) QueryBool(name string) (bool, error) {
+	return parseParam(sourceQuery, name, wc.GetParam(name), "a boolean", strconv.ParseBool)
+}
+
+// QueryUUID ... the query parameter as a UUID, a 400 HTTPError if it is missing or not one
+func (wc *WebContext) QueryUUID(name string) (UUID, error) {
+	return parseParam(sourceQuery, name, wc.GetParam(name), "a UUID", ParseUUID)
+}
+
+// QueryTime ... the query parameter as a time in the layout, a 400 HTTPError if it is missing or not one
+func (wc *WebContext) QueryTime(name string, layout string) (time.Time, error) {
+	return parseParam(sourceQuery, name, wc.GetParam(name), "a time like "+layout, timeParser(layout))
+}
+
+// QueryDuration ... the query parameter as a duration like 1h30m, a 400 HTTPError if it is missing or not one
+func (wc *WebContext) QueryDuration(name string) (time.Duration, error) {
+	return parseParam(sourceQuery, name, wc.GetParam(name), "a duration", time.ParseDuration)
+}
+
+// QueryIntDefault ... the query parameter as an int, def if it is missing
+func (wc *WebContext) QueryIntDefault(name string, def int) (int, error) {
+	return parseQueryDefault(wc, name, def, "an integer", strconv.Atoi)
+}
+
+// QueryInt64Default ... the query parameter as an int64, def if it is missing
+func (wc *WebContext) QueryInt64Default(name string, def int64) (int64, error) {
+	return parseQueryDefault(wc, name, def, "an integer", parseInt64)
+}
+
+// QueryUintDefault ... the query parameter as a uint, def if it is missing
+func (wc *WebContext) QueryUintDefault(name string, def uint) (uint, error) {
+	return parseQueryDefault(wc, name, def, "a positive integer", parseUint)
+}
+
+// QueryFloatDefault ... the query parameter as a float64, def if it is missing
+func (wc *WebContext) QueryFloatDefault(name string, def float64) (float64, error) {
+	return parseQueryDefault(wc, name, def, "a number", parseFloat)
+}
+
+// QueryBoolDefault ... the query parameter as a bool, def if it is missing
+func (wc *WebContext) QueryBoolDefault(name string, def bool) (bool, error) {
+	return parseQueryDefault(wc, name, def, "a boolean", strconv.ParseBool)
+}
+
+// QueryUUIDDefault ... the query parameter as a UUID, def if it is missing
+func (wc *WebContext) QueryUUIDDefault(name string, def UUID) (UUID, error) {
+	return parseQueryDefault(wc, name, def, "a UUID", ParseUUID)
+}
+
+// QueryTimeDefault ... the query parameter as a time in the layout, def if it is missing
+func (wc *WebContext) QueryTimeDefault(name string, layout string, def time.Time) (time.Time, error) {
+	return parseQueryDefault(wc, name, def, "a time like "+layout, timeParser(layout))
+}
+
+// QueryDurationDefault ... the query parameter as a duration like 1h30m, def if it is missing
+func (wc *WebContext) QueryDurationDefault(name string, def time.Duration) (time.Duration, error) {
+	return parseQueryDefault(wc, name, def, "a duration", time.ParseDuration)
+}
+
+// QueryStrings ... all the values of a repeated query parameter like ?tag=a&tag=b
+func (wc *WebContext) QueryStrings(name string) []string {
+	if wc.query == nil {
+		wc.query = wc.Request.URL.Query()
+	}
+	return wc.query[name]
+}
+
+// QueryInts ... all the values of a repeated query parameter as ints, nil if it is missing
+func (wc *WebContext) QueryInts(name string) ([]int, error) {
+	return parseQuerySlice(wc, name, "an integer", strconv.Atoi)
+}
+
+// QueryInt64s ... all the values of a repeated query parameter as int64s, nil if it is missing
+func (wc *WebContext) QueryInt64s(name string) ([]int64, error) {
+	return parseQuerySlice(wc, name, "an integer", parseInt64)
+}
+
+// QueryFloats ... all the values of a repeated query parameter as float64s, nil if it is missing
+func (wc *WebContext) QueryFloats(name string) ([]float64, error) {
+	return parseQuerySlice(wc, name, "a number", parseFloat)
+}
+
+// QueryUUIDs ... all the values of a repeated query parameter as UUIDs, nil if it is missing
+func (wc *WebContext) QueryUUIDs(name string) ([]UUID, error) {
+	return parseQuerySlice(wc, name, "a UUID", ParseUUID)
+}
@@ -0,0 +1,146 @@
+package gweb
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// routeRegistrar ... the route methods shared by Web and WebGroup
+type routeRegistrar interface {
+	Get(pattern string, f WebHandler, middlewares ...WebHandler) error
+	Post(pattern string, f WebHandler, middlewares ...WebHandler) error
+	Put(pattern string, f WebHandler, middlewares ...WebHandler) error
+	Delete(pattern string, f WebHandler, middlewares ...WebHandler) error
+	Patch(pattern string, f WebHandler, middlewares ...WebHandler) error
+	Options(pattern string, f WebHandler, middlewares ...WebHandler) error
+	Any(pattern string, f WebHandler, middlewares ...WebHandler) error
+	Handle(method string, pattern string, f WebHandler, middlewares ...WebHandler) error
+}
+
+// NamedRoute ... registers the next route with a name, the path can be built with URLFor
+type NamedRoute struct {
+	name   string
+	prefix string
+	w      *Web
+	r      routeRegistrar
+}
+
+// Named ... name the route registered next, for example web.Named("user").Get("/user/{id}", getUser)
+func (w *Web) Named(name string) *NamedRoute {
+	return &NamedRoute{name: name, w: w, r: w}
+}
+
+// Named ... name the route registered next under the group prefix
+func (wg *WebGroup) Named(name string) *NamedRoute {
+	return &NamedRoute{name: name, prefix: wg.prefix, w: wg.w, r: wg}
+}
+
+// Get ... adds a named GET handler
+func (n *NamedRoute) Get(pattern string, f WebHandler, middlewares ...WebHandler) error {
+	return n.add(pattern, func() error { return n.r.Get(pattern, f, middlewares...) })
+}
+
+// Post ... adds a named POST handler
+func (n *NamedRoute) Post(pattern string, f WebHandler, middlewares ...WebHandler) error {
+	return n.add(pattern, func() error { return n.r.Post(pattern, f, middlewares...) })
+}
+
+// Put ... adds a named PUT handler
+func (n *NamedRoute) Put(pattern string, f WebHandler, middlewares ...WebHandler) error {
+	return n.add(pattern, func() error { return n.r.Put(pattern, f, middlewares...) })
+}
+
+// Delete ... adds a named DELETE handler
+func (n *NamedRoute) Delete(pattern string, f WebHandler, middlewares ...WebHandler) error {
+	return n.add(pattern, func() error { return n.r.Delete(pattern, f, middlewares...) })
+}
+
+// Patch ... adds a named PATCH handler
+func (n *NamedRoute) Patch(pattern string, f WebHandler, middlewares ...WebHandler) error {
+	return n.add(pattern, func() error { return n.r.Patch(pattern, f, middlewares...) })
+}
+
+// Options ... adds a named OPTIONS handler
+func (n *NamedRoute) Options(pattern string, f WebHandler, middlewares ...WebHandler) error {
+	return n.add(pattern, func() error { return n.r.Options(pattern, f, middlewares...) })
+}
+
+// Any ... adds a named handler for all the methods
+func (n *NamedRoute) Any(pattern string, f WebHandler, middlewares ...WebHandler) error {
+	return n.add(pattern, func() error { return n.r.Any(pattern, f, middlewares...) })
+}
+
+// Handle ... adds a named handler for any method
+func (n *NamedRoute) Handle(method string, pattern string, f WebHandler, middlewares ...WebHandler) error {
+	return n.add(pattern, func() error { return n.r.Handle(method, pattern, f, middlewares...) })
+}
+
+// add ... checks the name, registers the route and remembers its full path
+func (n *NamedRoute) add(pattern string, register func() error) error {
+	if n.name == "" {
+		return errors.New(InvalidRouteName)
+	}
+	if _, ok := n.w.names[n.name]; ok {
+		return fmt.Errorf("%s: %q is already used", InvalidRouteName, n.name)
+	}
+	if err := register(); err != nil {
+		return err
+	}
+	n.w.names[n.name] = n.prefix + pattern
+	return nil
+}
+
+// URLFor ... builds the path of a named route
+// params ... the wildcard names and their values as pairs, for example URLFor("user", "id", 42)
+// values are path escaped, a {name...} wildcard keeps the / between its segments
+func (w *Web) URLFor(name string, params ...any) (string, error) {
+	pattern, ok := w.names[name]
+	if !ok {
+		return "", fmt.Errorf("%s: %q", UnknownRoute, name)
+	}
+	if len(params)%2 != 0 {
+		return "", fmt.Errorf("%s: %q has a parameter without a value", InvalidRouteParams, name)
+	}
+	values := make(map[string]string, len(params)/2)
+	for i := 0; i < len(params); i += 2 {
+		key, ok := params[i].(string)
+		if !ok {
+			return "", fmt.Errorf("%s: %q parameter name %v is not a string", InvalidRouteParams, name, params[i])
+		}
+		values[key] = fmt.Sprint(params[i+1])
+	}
+
+	segments := strings.Split(pattern, "/")
+	used := 0
+	for i, seg := range segments {
+		if !strings.HasPrefix(seg, "{") || !strings.HasSuffix(seg, "}") {
+			continue
+		}
+		wildcard := seg[1 : len(seg)-1]
+		if wildcard == "$" {
+			segments[i] = ""
+			continue
+		}
+		wildcard, rest := strings.CutSuffix(wildcard, "...")
+		value, ok := values[wildcard]
+		if !ok {
+			return "", fmt.Errorf("%s: %q is missing %q", InvalidRouteParams, name, wildcard)
+		}
+		used++
+		if !rest {
+			segments[i] = url.PathEscape(value)
+			continue
+		}
+		parts := strings.Split(value, "/")
+		for j, p := range parts {
+			parts[j] = url.PathEscape(p)
+		}
+		segments[i] = strings.Join(parts, "/")
+	}
+	if used != len(values) {
+		return "", fmt.Errorf("%s: %q got parameters not in %s", InvalidRouteParams, name, pattern)
+	}
+	return strings.Join(segments, "/"), nil
+}
@@ -0,0 +1,281 @@
+package gweb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// the first file descriptor passed by systemd socket activation
+const systemdFirstFd = 3
+
+// Run ... create a HTTP server and runs it on the host address provided
+// host ... it should be in the "ip:port" format, use port 0 to pick a free port
+// returns any error thorwn by the http server, http.ErrServerClosed after Shutdown
+func (w *Web) Run(host string) error {
+	w.httpServer.Addr = host
+	ln, err := net.Listen("tcp", host)
+	if err != nil {
+		return err
+	}
+	w.WebLog.Info("Running Gweb server", "host", ln.Addr().String())
+	return w.serve(w.httpServer.Serve, ln)
+}
+
+// Serve ... serves the connections accepted on ln, ln is closed when the server stops
+// returns any error thorwn by the http server, http.ErrServerClosed after Shutdown
+func (w *Web) Serve(ln net.Listener) error {
+	if ln == nil {
+		return errors.New(InvalidListener)
+	}
+	w.WebLog.Info("Running Gweb server", "host", ln.Addr().String())
+	return w.serve(w.httpServer.Serve, ln)
+}
+
+// RunUnix ... runs the server on a unix domain socket at path
+// a stale socket file left by a previous run is removed, the file permissions are set with WithSocketMode
+// returns any error thorwn by the http server, http.ErrServerClosed after Shutdown
+func (w *Web) RunUnix(path string) error {
+	if err := removeStaleSocket(path); err != nil {
+		return err
+	}
+	ln, err := listenUnix(path, w.socketMode)
+	if err != nil {
+		return err
+	}
+	w.WebLog.Info("Running Gweb server", "socket", path)
+	return w.serve(w.httpServer.Serve, ln)
+}
+
+// RunSystemd ... runs the server on all the listeners passed by systemd socket activation
+// returns any error thorwn by the http server, http.ErrServerClosed after Shutdown
+func (w *Web) RunSystemd() error {
+	lns, err := SystemdListeners()
+	if err != nil {
+		return err
+	}
+	if len(lns) == 0 {
+		return errors.New(NoSystemdListeners)
+	}
+	for _, ln := range lns {
+		w.WebLog.Info("Running Gweb server", "host", ln.Addr().String())
+	}
+	return w.serve(w.httpServer.Serve, lns...)
+}
+
+// SystemdListeners ... the listeners inherited from systemd using LISTEN_PID and LISTEN_FDS
+// returns nil if the process was not socket activated
+// the environment variables are cleared so child processes do not inherit them
+func SystemdListeners() ([]net.Listener, error) {
+	pid, err := strconv.Atoi(os.Getenv("LISTEN_PID"))
+	if err != nil || pid != os.Getpid() {
+		return nil, nil
+	}
+	n, err := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+	if err != nil || n <= 0 {
+		return nil, fmt.Errorf("%s: LISTEN_FDS=%q", InvalidListener, os.Getenv("LISTEN_FDS"))
+	}
+	names := strings.Split(os.Getenv("LISTEN_FDNAMES"), ":")
+	os.Unsetenv("LISTEN_PID")
+	os.Unsetenv("LISTEN_FDS")
+	os.Unsetenv("LISTEN_FDNAMES")
+
+	lns := make([]net.Listener, 0, n)
+	for i := 0; i < n; i++ {
+		name := "LISTEN_FD_" + strconv.Itoa(systemdFirstFd+i)
+		if i < len(names) && names[i] != "" {
+			name = names[i]
+		}
+		f := os.NewFile(uintptr(systemdFirstFd+i), name)
+		//FileListener dups the descriptor so the original can be closed
+		ln, err := net.FileListener(f)
+		f.Close()
+		if err != nil {
+			for _, l := range lns {
+				l.Close()
+			}
+			return nil, fmt.Errorf("%s: %s: %w", InvalidListener, name, err)
+		}
+		lns = append(lns, ln)
+	}
+	return lns, nil
+}
+
+// RunWithSignals ... runs the server like Run and waits for SIGINT or SIGTERM
+// on a signal the server is shutdown, in flight requests get up to timeout to finish
+// returns nil on a clean shutdown
+func (w *Web) RunWithSignals(host string, timeout time.Duration) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- w.Run(host)
+	}()
+	select {
+	case err := <-errCh:
+		//the server could not start
+		return err
+	case <-ctx.Done():
+	}
+	w.WebLog.Info("Shutting down Gweb server", "timeout", timeout.String())
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	err := w.Shutdown(shutdownCtx)
+	if runErr := <-errCh; !errors.Is(runErr, http.ErrServerClosed) {
+		err = errors.Join(err, runErr)
+	}
+	return err
+}
+
+// Shutdown ... gracefully stops the server without interrupting active connections
+// it waits for the in flight requests to finish or ctx to expire
+// then calls the OnShutdown hooks in the reverse order they were added
+func (w *Web) Shutdown(ctx context.Context) error {
+	err := w.httpServer.Shutdown(ctx)
+	for i := len(w.onShutdown) - 1; i >= 0; i-- {
+		if hookErr := w.onShutdown[i](ctx); hookErr != nil {
+			w.WebLog.Error("shutdown hook", "WebErr", hookErr)
+			err = errors.Join(err, hookErr)
+		}
+	}
+	return err
+}
+
+// OnStart ... add a hook called before the server starts accepting connections
+// hooks are called in the order they were added, an error stops the server from starting
+func (w *Web) OnStart(f Hook) {
+	if f == nil {
+		return
+	}
+	w.onStart = append(w.onStart, f)
+}
+
+// OnShutdown ... add a hook called by Shutdown once the in flight requests are drained
+// hooks are called in the reverse order they were added so dependencies stop in order
+func (w *Web) OnShutdown(f Hook) {
+	if f == nil {
+		return
+	}
+	w.onShutdown = append(w.onShutdown, f)
+}
+
+// Addr ... the address the server is listening on, nil if it is not running yet
+// with more than one listener the first one is returned
+func (w *Web) Addr() net.Addr {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if len(w.listeners) == 0 {
+		return nil
+	}
+	return w.listeners[0].Addr()
+}
+
+// serve ... runs the start hooks and serves the connections from the listeners with serveFn
+// returns the first error from any of the listeners
+func (w *Web) serve(serveFn func(net.Listener) error, lns ...net.Listener) error {
+	w.mu.Lock()
+	w.listeners = lns
+	w.mu.Unlock()
+	for _, f := range w.onStart {
+		if err := f(context.Background()); err != nil {
+			for _, ln := range lns {
+				ln.Close()
+			}
+			return err
+		}
+	}
+	if len(lns) == 1 {
+		return serveFn(lns[0])
+	}
+	errCh := make(chan error, len(lns))
+	for _, ln := range lns {
+		go func(ln net.Listener) {
+			errCh <- serveFn(ln)
+		}(ln)
+	}
+	err := <-errCh
+	if !errors.Is(err, http.ErrServerClosed) {
+		//one listener failed, the others stop accepting so the server does not keep running half way
+		for _, ln := range lns {
+			ln.Close()
+		}
+	}
+	return err
+}
+
+// removeStaleSocket ... removes a socket file nobody is listening on anymore
+func removeStaleSocket(path string) error {
+	info, err := os.Lstat(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if info.Mode()&os.ModeSocket == 0 {
+		return fmt.Errorf("%s: %s", NotASocket, path)
+	}
+	conn, err := net.DialTimeout("unix", path, time.Second)
+	if err == nil {
+		conn.Close()
+		return fmt.Errorf("%s: %s", SocketInUse, path)
+	}
+	return os.Remove(path)
+}
+
+// listenUnix ... listens on a socket created in a private directory and moved to path once it has its permissions
+// so the socket is never reachable with the permissions given by the umask
+func listenUnix(path string, mode os.FileMode) (net.Listener, error) {
+	dir, err := os.MkdirTemp(filepath.Dir(path), ".gweb-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+	tmp := filepath.Join(dir, "gweb.sock")
+	ln, err := net.ListenUnix("unix", &net.UnixAddr{Name: tmp, Net: "unix"})
+	if err != nil {
+		return nil, err
+	}
+	//the listener would remove the temp path, the socket is removed from path on Close instead
+	ln.SetUnlinkOnClose(false)
+	if err := os.Chmod(tmp, mode); err != nil {
+		ln.Close()
+		return nil, err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		ln.Close()
+		return nil, err
+	}
+	return &unixListener{UnixListener: ln, addr: &net.UnixAddr{Name: path, Net: "unix"}}, nil
+}
+
+// unixListener ... a unix listener that removes its socket file from path when it is closed
+type unixListener struct {
+	*net.UnixListener
+	addr   *net.UnixAddr
+	unlink sync.Once
+}
+
+func (l *unixListener) Addr() net.Addr {
+	return l.addr
+}
+
+func (l *unixListener) Close() error {
+	err := l.UnixListener.Close()
+	l.unlink.Do(func() {
+		os.Remove(l.addr.Name)
+	})
+	return err
+}
@@ -0,0 +1,175 @@
+package gweb
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"log/slog"
+	"net"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// PeerIdentity ... the identity of a client that presented a verified certificate
+type PeerIdentity struct {
+	Subject        string
+	CommonName     string
+	DNSNames       []string
+	EmailAddresses []string
+	IPAddresses    []net.IP
+	URIs           []string
+	//the verified leaf certificate
+	Certificate *x509.Certificate
+}
+
+// RunTLS ... create a HTTPS server and runs it on the host address provided
+// host ... it should be in the "ip:port" format
+// certFile and keyFile are reloaded from disk when they change, no restart needed
+// returns any error thorwn by the http server, http.ErrServerClosed after Shutdown
+func (w *Web) RunTLS(host string, certFile string, keyFile string) error {
+	reloader, err := newCertReloader(certFile, keyFile, w.WebLog)
+	if err != nil {
+		return err
+	}
+	var tlsConfig *tls.Config
+	if w.httpServer.TLSConfig != nil {
+		tlsConfig = w.httpServer.TLSConfig.Clone()
+	} else {
+		tlsConfig = &tls.Config{MinVersion: tls.VersionTLS12}
+	}
+	tlsConfig.Certificates = nil
+	tlsConfig.GetCertificate = reloader.getCertificate
+	w.httpServer.TLSConfig = tlsConfig
+	w.httpServer.Addr = host
+
+	ln, err := net.Listen("tcp", host)
+	if err != nil {
+		return err
+	}
+	w.WebLog.Info("Running Gweb TLS server", "host", ln.Addr().String())
+	return w.serve(func(ln net.Listener) error {
+		return w.httpServer.ServeTLS(ln, "", "")
+	}, ln)
+}
+
+// PeerIdentity ... the identity from the verified client certificate
+// returns nil if the connection is not TLS or the client certificate was not verified
+func (wc *WebContext) PeerIdentity() *PeerIdentity {
+	if wc.Request == nil || wc.Request.TLS == nil {
+		return nil
+	}
+	chains := wc.Request.TLS.VerifiedChains
+	if len(chains) == 0 || len(chains[0]) == 0 {
+		return nil
+	}
+	cert := chains[0][0]
+	identity := &PeerIdentity{
+		Subject:        cert.Subject.String(),
+		CommonName:     cert.Subject.CommonName,
+		DNSNames:       cert.DNSNames,
+		EmailAddresses: cert.EmailAddresses,
+		IPAddresses:    cert.IPAddresses,
+		Certificate:    cert,
+	}
+	for _, u := range cert.URIs {
+		identity.URIs = append(identity.URIs, u.String())
+	}
+	return identity
+}
+
+// certCheckInterval ... how often the handshakes look for new certificate files
+const certCheckInterval = time.Second
+
+// certReloader ... keeps the server certificate in sync with the files on disk
+// the files are checked at most once every certCheckInterval, the other handshakes only load the pointer
+type certReloader struct {
+	certFile string
+	keyFile  string
+	log      *slog.Logger
+
+	cert atomic.Pointer[tls.Certificate]
+	//unix nanoseconds of the last check
+	checked atomic.Int64
+
+	mu      sync.Mutex
+	certMod time.Time
+	keyMod  time.Time
+	//the files and the error of the last failed reload, it is only logged again when they change
+	failed certFailure
+}
+
+// certFailure ... a reload that failed for the files with these modification times
+type certFailure struct {
+	certMod time.Time
+	keyMod  time.Time
+	err     string
+}
+
+func newCertReloader(certFile string, keyFile string, log *slog.Logger) (*certReloader, error) {
+	if certFile == "" || keyFile == "" {
+		return nil, errors.New(InvalidCertificate)
+	}
+	c := &certReloader{certFile: certFile, keyFile: keyFile, log: log}
+	if err := c.reload(); err != nil {
+		return nil, err
+	}
+	c.checked.Store(time.Now().UnixNano())
+	return c, nil
+}
+
+// reload ... load the key pair if the files changed since the last load
+func (c *certReloader) reload() error {
+	var failure certFailure
+	defer func() {
+		c.failed = failure
+	}()
+	certInfo, err := os.Stat(c.certFile)
+	if err != nil {
+		return c.reloadFailed(&failure, err)
+	}
+	failure.certMod = certInfo.ModTime()
+	keyInfo, err := os.Stat(c.keyFile)
+	if err != nil {
+		return c.reloadFailed(&failure, err)
+	}
+	failure.keyMod = keyInfo.ModTime()
+	if c.cert.Load() != nil && certInfo.ModTime().Equal(c.certMod) && keyInfo.ModTime().Equal(c.keyMod) {
+		return nil
+	}
+	cert, err := tls.LoadX509KeyPair(c.certFile, c.keyFile)
+	if err != nil {
+		return c.reloadFailed(&failure, err)
+	}
+	c.cert.Store(&cert)
+	c.certMod = certInfo.ModTime()
+	c.keyMod = keyInfo.ModTime()
+	return nil
+}
+
+// reloadFailed ... logs the error unless it was already logged for the same files
+func (c *certReloader) reloadFailed(failure *certFailure, err error) error {
+	failure.err = err.Error()
+	logged := failure.err == c.failed.err && failure.certMod.Equal(c.failed.certMod) && failure.keyMod.Equal(c.failed.keyMod)
+	if !logged && c.log != nil && c.cert.Load() != nil {
+		c.log.Error("reloading the TLS certificate failed, the last good certificate is served",
+			"certFile", c.certFile, "keyFile", c.keyFile, "WebErr", err)
+	}
+	return err
+}
+
+// getCertificate ... used as tls.Config.GetCertificate
+// if the new files can not be loaded the last good certificate is served
+func (c *certReloader) getCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	now := time.Now().UnixNano()
+	last := c.checked.Load()
+	//only one handshake checks the files, the others keep the current certificate
+	if now-last >= int64(certCheckInterval) && c.checked.CompareAndSwap(last, now) {
+		c.mu.Lock()
+		//a half written pair fails to load, it is picked up on the next check
+		_ = c.reload()
+		c.mu.Unlock()
+	}
+	return c.cert.Load(), nil
+}
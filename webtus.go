@@ -0,0 +1,560 @@
+package gweb
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// the version of the tus protocol and the extensions served by TusServer
+const (
+	TusVersion    = "1.0.0"
+	tusExtensions = "creation,termination,expiration"
+	tusChunkType  = "application/offset+octet-stream"
+)
+
+// ErrUploadNotFound ... returned by a TusStore for an upload it does not have
+var ErrUploadNotFound = errors.New(UploadNotFound)
+
+// TusInfo ... the state of a tus upload
+type TusInfo struct {
+	ID string `json:"id"`
+	//the size of the file
+	Length int64 `json:"length"`
+	//the bytes received so far
+	Offset int64 `json:"offset"`
+	//the Upload-Metadata sent by the client when the upload was created
+	Metadata  map[string]string `json:"metadata,omitempty"`
+	CreatedAt time.Time         `json:"createdAt"`
+	//when an unfinished upload is removed, zero if it never expires
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+// Complete ... true once every byte of the upload has been received
+func (i TusInfo) Complete() bool {
+	return i.Offset == i.Length
+}
+
+// expired ... true for an unfinished upload past its expiry
+func (i TusInfo) expired(now time.Time) bool {
+	return !i.Complete() && !i.ExpiresAt.IsZero() && now.After(i.ExpiresAt)
+}
+
+// TusStore ... where TusServer keeps the uploads
+// the server calls the store for one upload at a time, ErrUploadNotFound is returned for an unknown id
+type TusStore interface {
+	//Create ... adds a new upload with no data
+	Create(ctx context.Context, info TusInfo) error
+	//Info ... the current state of an upload
+	Info(ctx context.Context, id string) (TusInfo, error)
+	//WriteChunk ... appends the data at offset, the bytes written are kept even when there is an error
+	WriteChunk(ctx context.Context, id string, offset int64, r io.Reader) (int64, error)
+	//Open ... reads the data of an upload
+	Open(ctx context.Context, id string) (io.ReadCloser, error)
+	//Terminate ... removes an upload and its data
+	Terminate(ctx context.Context, id string) error
+	//Expired ... the ids of the unfinished uploads that expired before now
+	Expired(ctx context.Context, now time.Time) ([]string, error)
+}
+
+// TusConfig ... the settings of a TusServer
+type TusConfig struct {
+	//where the uploads are kept, for example a TusFileStore
+	Store TusStore
+	//the largest upload accepted, 0 for no limit
+	MaxSize int64
+	//how long an unfinished upload is kept, 0 keeps it until it is terminated
+	Expiration time.Duration
+	//run in order when the last chunk of an upload is received, the upload is in TusUploadInfo
+	OnComplete []WebHandler
+}
+
+// TusServer ... a tus 1.0 resumable upload server, see https://tus.io/protocols/resumable-upload
+// it supports the creation, termination and expiration extensions
+type TusServer struct {
+	config TusConfig
+	//a mutex for every upload in use, so its chunks are written one at a time
+	//an entry only exists while a request holds or waits for it
+	mu    sync.Mutex
+	locks map[string]*uploadLock
+}
+
+// uploadLock ... the mutex of an upload and the requests holding or waiting for it
+type uploadLock struct {
+	sync.Mutex
+	refs int
+}
+
+// NewTusServer ... creates the server, mount it on a group with Mount
+func NewTusServer(config TusConfig) (*TusServer, error) {
+	if config.Store == nil || config.MaxSize < 0 || config.Expiration < 0 {
+		return nil, errors.New(InvalidTusConfig)
+	}
+	return &TusServer{config: config, locks: make(map[string]*uploadLock)}, nil
+}
+
+// Mount ... adds the tus routes to the group, uploads are created with a POST to the group path with or without the trailing slash
+// and each upload has its own path under it, the middlewares run before every tus request
+func (t *TusServer) Mount(wg *WebGroup, middlewares ...WebHandler) error {
+	if wg == nil {
+		return errors.New(InvalidWebGroup)
+	}
+	for _, route := range []struct {
+		method  string
+		pattern string
+		handler WebHandler
+	}{
+		{http.MethodOptions, "/{$}", t.options},
+		{http.MethodPost, "/{$}", t.create(wg.prefix)},
+		{http.MethodOptions, "/{id}", t.options},
+		{http.MethodHead, "/{id}", t.head},
+		{http.MethodPatch, "/{id}", t.patch},
+		{http.MethodDelete, "/{id}", t.terminate},
+	} {
+		if err := wg.Handle(route.method, route.pattern, t.resumable(route.handler), middlewares...); err != nil {
+			return err
+		}
+		if route.pattern == "/{$}" && wg.prefix != "" {
+			//the group path without the trailing slash, the mux would redirect it and a preflight can not follow that
+			if err := wg.w.addRoutes(route.method+" "+wg.prefix, t.resumable(route.handler), wg, middlewares...); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// CleanupExpired ... terminates the unfinished uploads that have expired, it returns how many were removed
+// expired uploads are refused as soon as they expire, call it from time to time to free the storage
+func (t *TusServer) CleanupExpired(ctx context.Context) (int, error) {
+	ids, err := t.config.Store.Expired(ctx, time.Now())
+	if err != nil {
+		return 0, err
+	}
+	removed := 0
+	var errs []error
+	for _, id := range ids {
+		//wait for a chunk being written to the upload
+		unlock := t.lock(id)
+		err := t.config.Store.Terminate(ctx, id)
+		unlock()
+		if err != nil && !errors.Is(err, ErrUploadNotFound) {
+			errs = append(errs, err)
+			continue
+		}
+		removed++
+	}
+	return removed, errors.Join(errs...)
+}
+
+// tusInfoKey ... the key of the completed upload in the request context
+type tusInfoKey struct{}
+
+// TusUploadInfo ... the upload that was completed, for the OnComplete handlers of a TusServer
+func TusUploadInfo(wc *WebContext) (TusInfo, bool) {
+	info, ok := wc.Request.Context().Value(tusInfoKey{}).(TusInfo)
+	return info, ok
+}
+
+// resumable ... sets Tus-Resumable on every response and refuses the requests for another version
+func (t *TusServer) resumable(handler WebHandler) WebHandler {
+	return func(wc *WebContext) error {
+		wc.Writer.Header().Set("Tus-Resumable", TusVersion)
+		if wc.Request.Method != http.MethodOptions && wc.Request.Header.Get("Tus-Resumable") != TusVersion {
+			wc.Writer.Header().Set("Tus-Version", TusVersion)
+			return NewHTTPError(http.StatusPreconditionFailed, "unsupported tus version").WithCode("tus_version")
+		}
+		return handler(wc)
+	}
+}
+
+// options ... the versions, extensions and limits of the server
+func (t *TusServer) options(wc *WebContext) error {
+	h := wc.Writer.Header()
+	h.Set("Tus-Version", TusVersion)
+	h.Set("Tus-Extension", tusExtensions)
+	if t.config.MaxSize > 0 {
+		h.Set("Tus-Max-Size", strconv.FormatInt(t.config.MaxSize, 10))
+	}
+	wc.Writer.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+// create ... a new upload of Upload-Length bytes, its URL is in the Location of the response
+func (t *TusServer) create(prefix string) WebHandler {
+	return func(wc *WebContext) error {
+		length, err := strconv.ParseInt(wc.Request.Header.Get("Upload-Length"), 10, 64)
+		if err != nil || length < 0 {
+			return NewHTTPError(http.StatusBadRequest, "invalid Upload-Length").WithCode("invalid_upload_length")
+		}
+		if t.config.MaxSize > 0 && length > t.config.MaxSize {
+			return NewHTTPError(http.StatusRequestEntityTooLarge, fmt.Sprintf("upload larger than %d bytes", t.config.MaxSize)).
+				WithCode("upload_too_large")
+		}
+		metadata, err := parseTusMetadata(wc.Request.Header.Get("Upload-Metadata"))
+		if err != nil {
+			return NewHTTPError(http.StatusBadRequest, "invalid Upload-Metadata").WithCode("invalid_upload_metadata").Wrap(err)
+		}
+		id, err := newUploadID()
+		if err != nil {
+			return err
+		}
+		now := time.Now()
+		info := TusInfo{ID: id, Length: length, Metadata: metadata, CreatedAt: now}
+		if t.config.Expiration > 0 {
+			info.ExpiresAt = now.Add(t.config.Expiration)
+		}
+		if err := t.config.Store.Create(wc.Request.Context(), info); err != nil {
+			return err
+		}
+		h := wc.Writer.Header()
+		h.Set("Location", prefix+"/"+id)
+		setUploadExpires(h, info)
+		if info.Complete() {
+			//an empty file is complete once it is created
+			if err := t.complete(wc, info); err != nil {
+				return err
+			}
+		}
+		if !wc.Writer.Written() {
+			wc.Writer.WriteHeader(http.StatusCreated)
+		}
+		return nil
+	}
+}
+
+// head ... the offset of an upload, the client resumes from it
+func (t *TusServer) head(wc *WebContext) error {
+	info, err := t.info(wc, wc.GetPathValue("id"))
+	if err != nil {
+		return err
+	}
+	h := wc.Writer.Header()
+	h.Set("Cache-Control", "no-store")
+	h.Set("Upload-Offset", strconv.FormatInt(info.Offset, 10))
+	h.Set("Upload-Length", strconv.FormatInt(info.Length, 10))
+	if len(info.Metadata) > 0 {
+		h.Set("Upload-Metadata", formatTusMetadata(info.Metadata))
+	}
+	setUploadExpires(h, info)
+	wc.Writer.WriteHeader(http.StatusOK)
+	return nil
+}
+
+// patch ... appends a chunk at Upload-Offset, the offset must be the one the server has
+func (t *TusServer) patch(wc *WebContext) error {
+	if wc.Request.Header.Get("Content-Type") != tusChunkType {
+		return NewHTTPError(http.StatusUnsupportedMediaType, "expected "+tusChunkType).WithCode("unsupported_media_type")
+	}
+	offset, err := strconv.ParseInt(wc.Request.Header.Get("Upload-Offset"), 10, 64)
+	if err != nil || offset < 0 {
+		return NewHTTPError(http.StatusBadRequest, "invalid Upload-Offset").WithCode("invalid_upload_offset")
+	}
+	id := wc.GetPathValue("id")
+	unlock := t.lock(id)
+	defer unlock()
+	info, err := t.info(wc, id)
+	if err != nil {
+		return err
+	}
+	if offset != info.Offset {
+		return NewHTTPError(http.StatusConflict, fmt.Sprintf("Upload-Offset %d does not match the offset %d", offset, info.Offset)).
+			WithCode("offset_mismatch")
+	}
+	//anything past the length of the upload is not read
+	n, err := t.config.Store.WriteChunk(wc.Request.Context(), id, offset, io.LimitReader(wc.Request.Body, info.Length-offset))
+	info.Offset += n
+	if err != nil {
+		return err
+	}
+	h := wc.Writer.Header()
+	h.Set("Upload-Offset", strconv.FormatInt(info.Offset, 10))
+	setUploadExpires(h, info)
+	if info.Complete() {
+		if err := t.complete(wc, info); err != nil {
+			return err
+		}
+	}
+	if !wc.Writer.Written() {
+		wc.Writer.WriteHeader(http.StatusNoContent)
+	}
+	return nil
+}
+
+// terminate ... removes an upload
+func (t *TusServer) terminate(wc *WebContext) error {
+	id := wc.GetPathValue("id")
+	unlock := t.lock(id)
+	defer unlock()
+	if err := t.config.Store.Terminate(wc.Request.Context(), id); err != nil {
+		return tusStoreError(err)
+	}
+	wc.Writer.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+// info ... the upload, a 404 if there is none and a 410 if it expired
+func (t *TusServer) info(wc *WebContext, id string) (TusInfo, error) {
+	info, err := t.config.Store.Info(wc.Request.Context(), id)
+	if err != nil {
+		return info, tusStoreError(err)
+	}
+	if info.expired(time.Now()) {
+		t.config.Store.Terminate(wc.Request.Context(), id)
+		return info, NewHTTPError(http.StatusGone, "upload expired").WithCode("upload_expired")
+	}
+	return info, nil
+}
+
+// complete ... runs the OnComplete handlers with the upload in the request context
+func (t *TusServer) complete(wc *WebContext, info TusInfo) error {
+	wc.Request = wc.Request.WithContext(context.WithValue(wc.Request.Context(), tusInfoKey{}, info))
+	for _, handler := range t.config.OnComplete {
+		if err := handler(wc); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// lock ... locks the upload, it returns the unlock
+// the mutex is removed by the last unlock, so ids of unknown or finished uploads do not pile up
+func (t *TusServer) lock(id string) func() {
+	t.mu.Lock()
+	l, ok := t.locks[id]
+	if !ok {
+		l = &uploadLock{}
+		t.locks[id] = l
+	}
+	l.refs++
+	t.mu.Unlock()
+	l.Lock()
+	return func() {
+		l.Unlock()
+		t.mu.Lock()
+		l.refs--
+		if l.refs == 0 {
+			delete(t.locks, id)
+		}
+		t.mu.Unlock()
+	}
+}
+
+// tusStoreError ... a 404 for an unknown upload, other errors are returned as they are
+func tusStoreError(err error) error {
+	if errors.Is(err, ErrUploadNotFound) {
+		return NewHTTPError(http.StatusNotFound, "upload not found").WithCode("upload_not_found").Wrap(err)
+	}
+	return err
+}
+
+// setUploadExpires ... the expiry of an unfinished upload
+func setUploadExpires(h http.Header, info TusInfo) {
+	if !info.ExpiresAt.IsZero() && !info.Complete() {
+		h.Set("Upload-Expires", info.ExpiresAt.UTC().Format(http.TimeFormat))
+	}
+}
+
+// newUploadID ... a random id for an upload
+func newUploadID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// parseTusMetadata ... parses an Upload-Metadata header like "filename d29ybGQ=,is_confidential"
+func parseTusMetadata(header string) (map[string]string, error) {
+	if strings.TrimSpace(header) == "" {
+		return nil, nil
+	}
+	metadata := make(map[string]string)
+	for _, pair := range strings.Split(header, ",") {
+		key, value, _ := strings.Cut(strings.TrimSpace(pair), " ")
+		if key == "" {
+			return nil, errors.New("empty key")
+		}
+		decoded, err := base64.StdEncoding.DecodeString(value)
+		if err != nil {
+			return nil, fmt.Errorf("value of %s: %w", key, err)
+		}
+		metadata[key] = string(decoded)
+	}
+	return metadata, nil
+}
+
+// formatTusMetadata ... the Upload-Metadata header of the metadata
+func formatTusMetadata(metadata map[string]string) string {
+	pairs := make([]string, 0, len(metadata))
+	for key, value := range metadata {
+		if value == "" {
+			pairs = append(pairs, key)
+			continue
+		}
+		pairs = append(pairs, key+" "+base64.StdEncoding.EncodeToString([]byte(value)))
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+// TusFileStore ... a TusStore on the local filesystem
+// every upload has a data file and an .info file with its TusInfo as JSON in the directory
+type TusFileStore struct {
+	dir string
+}
+
+// NewTusFileStore ... creates the directory if it does not exist
+func NewTusFileStore(dir string) (*TusFileStore, error) {
+	if err := os.MkdirAll(dir, 0750); err != nil {
+		return nil, err
+	}
+	return &TusFileStore{dir: dir}, nil
+}
+
+// Path ... the data file of an upload
+func (s *TusFileStore) Path(id string) string {
+	return filepath.Join(s.dir, id)
+}
+
+// Create ... creates the data and the info file
+func (s *TusFileStore) Create(_ context.Context, info TusInfo) error {
+	if !validUploadID(info.ID) {
+		return fmt.Errorf("%s: %q", InvalidUploadID, info.ID)
+	}
+	f, err := os.OpenFile(s.Path(info.ID), os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0640)
+	if err != nil {
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return s.writeInfo(info)
+}
+
+// Info ... reads the info file
+func (s *TusFileStore) Info(_ context.Context, id string) (TusInfo, error) {
+	var info TusInfo
+	if !validUploadID(id) {
+		return info, ErrUploadNotFound
+	}
+	b, err := os.ReadFile(s.Path(id) + ".info")
+	if errors.Is(err, os.ErrNotExist) {
+		return info, ErrUploadNotFound
+	}
+	if err != nil {
+		return info, err
+	}
+	err = json.Unmarshal(b, &info)
+	return info, err
+}
+
+// WriteChunk ... writes the data at offset and saves the new offset
+func (s *TusFileStore) WriteChunk(ctx context.Context, id string, offset int64, r io.Reader) (int64, error) {
+	info, err := s.Info(ctx, id)
+	if err != nil {
+		return 0, err
+	}
+	f, err := os.OpenFile(s.Path(id), os.O_WRONLY, 0)
+	if err != nil {
+		return 0, err
+	}
+	n, copyErr := io.Copy(io.NewOffsetWriter(f, offset), r)
+	if err := f.Close(); err != nil && copyErr == nil {
+		copyErr = err
+	}
+	//the bytes received are kept, the client resumes after them
+	info.Offset = offset + n
+	if err := s.writeInfo(info); err != nil {
+		return n, err
+	}
+	return n, copyErr
+}
+
+// Open ... opens the data file
+func (s *TusFileStore) Open(_ context.Context, id string) (io.ReadCloser, error) {
+	if !validUploadID(id) {
+		return nil, ErrUploadNotFound
+	}
+	f, err := os.Open(s.Path(id))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrUploadNotFound
+	}
+	return f, err
+}
+
+// Terminate ... removes the data and the info file
+func (s *TusFileStore) Terminate(_ context.Context, id string) error {
+	if !validUploadID(id) {
+		return ErrUploadNotFound
+	}
+	err := os.Remove(s.Path(id) + ".info")
+	if errors.Is(err, os.ErrNotExist) {
+		return ErrUploadNotFound
+	}
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(s.Path(id)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// Expired ... reads every info file in the directory
+func (s *TusFileStore) Expired(ctx context.Context, now time.Time) ([]string, error) {
+	infos, err := filepath.Glob(filepath.Join(s.dir, "*.info"))
+	if err != nil {
+		return nil, err
+	}
+	var ids []string
+	for _, path := range infos {
+		info, err := s.Info(ctx, strings.TrimSuffix(filepath.Base(path), ".info"))
+		if err != nil {
+			continue
+		}
+		if info.expired(now) {
+			ids = append(ids, info.ID)
+		}
+	}
+	return ids, nil
+}
+
+// writeInfo ... replaces the info file, it is renamed into place so a crash never leaves half of it
+func (s *TusFileStore) writeInfo(info TusInfo) error {
+	b, err := json.Marshal(info)
+	if err != nil {
+		return err
+	}
+	tmp := s.Path(info.ID) + ".info.tmp"
+	if err := os.WriteFile(tmp, b, 0640); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.Path(info.ID)+".info")
+}
+
+// validUploadID ... the ids are hex so they are safe as file names
+func validUploadID(id string) bool {
+	if id == "" {
+		return false
+	}
+	for _, c := range id {
+		if !strings.ContainsRune("0123456789abcdef", c) {
+			return false
+		}
+	}
+	return true
+}
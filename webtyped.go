@@ -0,0 +1,256 @@
+package gweb
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"reflect"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// StatusCoder ... implemented by a response to choose its status, the default is 200
+type StatusCoder interface {
+	StatusCode() int
+}
+
+// BodyEncoder ... encodes v as the body of the response
+type BodyEncoder func(w io.Writer, v any) error
+
+// the builtin encoders in the order they are preferred when the client accepts any type
+var builtinEncoders = []string{"application/json", "application/xml", "text/xml"}
+
+// RegisterEncoder ... adds the encoder used by Respond and Typed for a media type like text/csv
+// it replaces the builtin encoder for the media type
+func (w *Web) RegisterEncoder(mediaType string, encoder BodyEncoder) error {
+	parsed, _, err := mime.ParseMediaType(mediaType)
+	if err != nil || encoder == nil || strings.Contains(parsed, "*") {
+		return fmt.Errorf("%s: %q", InvalidEncoder, mediaType)
+	}
+	if w.encoders == nil {
+		w.encoders = make(map[string]BodyEncoder)
+	}
+	w.encoders[parsed] = encoder
+	return nil
+}
+
+// Typed ... makes a handler from a function taking a request and returning a response
+// the body is decoded with ParseBody, a struct Req then gets its path, query, header and cookie fields like Bind
+// and is checked with Validate, the body does not set the fields that come from those sources
+// the response is encoded with Respond in the type the client accepts, its status comes from StatusCoder
+// a nil response is a 204, an error goes to the ErrorHandler
+func Typed[Req any, Resp any](f func(ctx context.Context, req Req) (Resp, error)) WebHandler {
+	return func(wc *WebContext) error {
+		req := new(Req)
+		var target any = req
+		if rv := reflect.ValueOf(req).Elem(); rv.Kind() == reflect.Pointer {
+			//a pointer Req gets a new value to fill
+			rv.Set(reflect.New(rv.Type().Elem()))
+			target = rv.Interface()
+		}
+		if err := wc.bindRequest(target); err != nil {
+			return err
+		}
+		resp, err := f(wc.Context(), *req)
+		if err != nil {
+			return err
+		}
+		status := http.StatusOK
+		if coder, ok := any(resp).(StatusCoder); ok && !isNil(resp) {
+			status = coder.StatusCode()
+		}
+		return wc.Respond(status, resp)
+	}
+}
+
+// bindRequest ... fills the request of a typed handler
+func (wc *WebContext) bindRequest(v any) error {
+	if reflect.ValueOf(v).Elem().Kind() != reflect.Struct {
+		if !hasBody(wc.Request) {
+			return nil
+		}
+		return wc.ParseBody(v)
+	}
+	if err := wc.bind(v, true); err != nil {
+		return err
+	}
+	return wc.Validate(v)
+}
+
+// Respond ... encodes v with the status in the media type the client prefers in its Accept header
+// JSON is used when the client accepts any type, XML and the registered encoders are chosen by Accept
+// a 406 HTTPError is returned if no encoder matches, a nil v or a 204 only sends the status
+func (wc *WebContext) Respond(status int, v any) error {
+	if isNil(v) && status == http.StatusOK {
+		status = http.StatusNoContent
+	}
+	if status == http.StatusNoContent || status == http.StatusNotModified || isNil(v) {
+		wc.Status(status)
+		wc.Writer.WriteHeader(status)
+		return nil
+	}
+	mediaType, encoder := wc.negotiate()
+	if encoder == nil {
+		return NewHTTPError(http.StatusNotAcceptable, "no acceptable type, the supported types are "+
+			strings.Join(wc.encoderTypes(), ", ")).WithCode("not_acceptable")
+	}
+	h := wc.Writer.Header()
+	h.Add("Vary", "Accept")
+	if strings.HasPrefix(mediaType, "text/") || strings.HasSuffix(mediaType, "xml") {
+		h.Set("Content-Type", mediaType+"; charset=utf-8")
+	} else {
+		h.Set("Content-Type", mediaType)
+	}
+	wc.Status(status)
+	if err := encoder(wc.Writer, v); err != nil {
+		wc.WebLog.Error("encoding response", "WebErr", err)
+		return err
+	}
+	return nil
+}
+
+// negotiate ... the media type and the encoder for the Accept header, a nil encoder if none is accepted
+func (wc *WebContext) negotiate() (string, BodyEncoder) {
+	types := wc.encoderTypes()
+	accept := wc.Request.Header.Get("Accept")
+	if strings.TrimSpace(accept) == "" {
+		return types[0], wc.encoderFor(types[0])
+	}
+	ranges := parseAccept(accept)
+	for _, r := range ranges {
+		if r.q <= 0 {
+			//the ranges with q=0 are last, only exclusions are left
+			break
+		}
+		for _, t := range types {
+			if r.matches(t) && !excluded(ranges, t) {
+				return t, wc.encoderFor(t)
+			}
+		}
+	}
+	return "", nil
+}
+
+// excluded ... true if the most specific range that matches the media type has q=0
+// so application/json;q=0 rejects JSON even with */* and */*;q=0 does not reject application/json
+func excluded(ranges []acceptRange, mediaType string) bool {
+	best := -1
+	var q float64
+	for _, r := range ranges {
+		if r.matches(mediaType) && r.specificity() > best {
+			best = r.specificity()
+			q = r.q
+		}
+	}
+	return best >= 0 && q <= 0
+}
+
+// encoderTypes ... the builtin types then the registered ones
+func (wc *WebContext) encoderTypes() []string {
+	types := append([]string{}, builtinEncoders...)
+	if wc.web == nil {
+		return types
+	}
+	var registered []string
+	for t := range wc.web.encoders {
+		if !slices.Contains(builtinEncoders, t) {
+			registered = append(registered, t)
+		}
+	}
+	sort.Strings(registered)
+	return append(types, registered...)
+}
+
+// encoderFor ... the registered or builtin encoder of the media type
+func (wc *WebContext) encoderFor(mediaType string) BodyEncoder {
+	if wc.web != nil {
+		if encoder, ok := wc.web.encoders[mediaType]; ok {
+			return encoder
+		}
+	}
+	switch mediaType {
+	case "application/json":
+		return func(w io.Writer, v any) error {
+			return json.NewEncoder(w).Encode(v)
+		}
+	case "application/xml", "text/xml":
+		return func(w io.Writer, v any) error {
+			if _, err := io.WriteString(w, xml.Header); err != nil {
+				return err
+			}
+			return xml.NewEncoder(w).Encode(v)
+		}
+	}
+	return nil
+}
+
+// acceptRange ... a media range of the Accept header
+type acceptRange struct {
+	mediaType string
+	q         float64
+}
+
+// matches ... true if the range includes the media type, like */* or text/*
+func (r acceptRange) matches(mediaType string) bool {
+	if r.mediaType == "*/*" || r.mediaType == mediaType {
+		return true
+	}
+	prefix, ok := strings.CutSuffix(r.mediaType, "/*")
+	return ok && strings.HasPrefix(mediaType, prefix+"/")
+}
+
+// specificity ... exact types before type/* before */*
+func (r acceptRange) specificity() int {
+	switch {
+	case r.mediaType == "*/*":
+		return 0
+	case strings.HasSuffix(r.mediaType, "/*"):
+		return 1
+	}
+	return 2
+}
+
+// parseAccept ... the ranges of the Accept header from the most to the least preferred, the q=0 exclusions are last
+func parseAccept(header string) []acceptRange {
+	var ranges []acceptRange
+	for _, part := range strings.Split(header, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		q := 1.0
+		if v, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(v, 64); err != nil {
+				continue
+			}
+		}
+		ranges = append(ranges, acceptRange{mediaType: mediaType, q: q})
+	}
+	sort.SliceStable(ranges, func(i, j int) bool {
+		if ranges[i].q != ranges[j].q {
+			return ranges[i].q > ranges[j].q
+		}
+		return ranges[i].specificity() > ranges[j].specificity()
+	})
+	return ranges
+}
+
+// hasBody ... true if the request has a body to read
+func hasBody(r *http.Request) bool {
+	return r.Body != nil && r.Body != http.NoBody && r.ContentLength != 0
+}
+
+// isNil ... true for nil and for a nil pointer, a nil slice or map is still encoded
+func isNil(v any) bool {
+	if v == nil {
+		return true
+	}
+	rv := reflect.ValueOf(v)
+	return (rv.Kind() == reflect.Pointer || rv.Kind() == reflect.Interface) && rv.IsNil()
+}
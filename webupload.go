@@ -0,0 +1,296 @@
+package gweb
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// maxFormValueBytes ... the most read for all the values that are not files in a multipart upload
+const maxFormValueBytes = 10 << 20
+
+// sniffLen ... the bytes used to detect the type of a file
+const sniffLen = 512
+
+// UploadLimits ... the limits of the files in a multipart upload, 0 is no limit
+type UploadLimits struct {
+	//the largest file, a larger one is a 413
+	MaxFileSize int64
+	//the largest size of all the files together, more is a 413
+	MaxTotalSize int64
+	//the most files in one upload, more is a 413
+	MaxFiles int
+	//the media types allowed like image/png or image/*, detected from the content and not from the header
+	//any other type is a 415, empty allows all
+	AllowedTypes []string
+	//where the temp files of MultipartFiles are created, os.TempDir() if empty
+	TempDir string
+}
+
+// check ... the limits must not be negative
+func (l UploadLimits) check() error {
+	if l.MaxFileSize < 0 || l.MaxTotalSize < 0 || l.MaxFiles < 0 {
+		return errors.New(InvalidUploadLimits)
+	}
+	return nil
+}
+
+// UseUploadLimits ... a middleware that sets the upload limits for the rest of the chain
+// it replaces the limits of the Web for a route or a group
+func UseUploadLimits(limits UploadLimits) WebHandler {
+	return func(wc *WebContext) error {
+		wc.uploadLimits = &limits
+		return wc.Next()
+	}
+}
+
+// UploadedFile ... a file of a multipart upload
+type UploadedFile struct {
+	//the name of the form field
+	Field string
+	//the name of the file sent by the client, without any directory
+	Filename string
+	//the headers of the part, the Content-Type sent by the client is in here
+	Header textproto.MIMEHeader
+	//the media type detected from the content
+	ContentType string
+	//the size in bytes
+	Size int64
+	//the temp file, empty when the file was streamed to a writer
+	path string
+}
+
+// Open ... opens the temp file of an upload read by MultipartFiles
+// the temp file is removed at the end of the request, use SaveUploadedFile to keep it
+func (f *UploadedFile) Open() (*os.File, error) {
+	if f.path == "" {
+		return nil, errors.New(UploadNotOnDisk)
+	}
+	return os.Open(f.path)
+}
+
+// uploadLimitsFor ... the limits of the route, then the ones of the Web
+func (wc *WebContext) uploadLimitsFor() UploadLimits {
+	if wc.uploadLimits != nil {
+		return *wc.uploadLimits
+	}
+	if wc.web != nil && wc.web.uploadLimits != nil {
+		return *wc.web.uploadLimits
+	}
+	return UploadLimits{}
+}
+
+// MultipartFiles ... reads the multipart body streaming every file to a temp file
+// the temp files are removed at the end of the request, the other form values are in Request.PostForm
+// the body is read once, later calls return the same files
+func (wc *WebContext) MultipartFiles() ([]*UploadedFile, error) {
+	if wc.uploadsRead {
+		return wc.uploads, nil
+	}
+	limits := wc.uploadLimitsFor()
+	var temps []*os.File
+	defer func() {
+		for _, tmp := range temps {
+			tmp.Close()
+		}
+	}()
+	return wc.StreamFiles(func(f *UploadedFile) (io.Writer, error) {
+		tmp, err := os.CreateTemp(limits.TempDir, "gweb-upload-*")
+		if err != nil {
+			return nil, err
+		}
+		temps = append(temps, tmp)
+		path := tmp.Name()
+		wc.cleanups = append(wc.cleanups, func() {
+			os.Remove(path)
+		})
+		f.path = path
+		return tmp, nil
+	})
+}
+
+// StreamFiles ... reads the multipart body copying every file to the writer returned by dst
+// dst gets the file with its name and detected type before the content is copied, the size is set after
+// the limits of the route are enforced while copying, the other form values are in Request.PostForm
+func (wc *WebContext) StreamFiles(dst func(f *UploadedFile) (io.Writer, error)) ([]*UploadedFile, error) {
+	if wc.uploadsRead {
+		return wc.uploads, nil
+	}
+	r := wc.Request
+	if r.Form == nil {
+		//the query values, a multipart body is not read by ParseForm
+		r.ParseForm()
+	}
+	reader, err := r.MultipartReader()
+	if err != nil {
+		if errors.Is(err, http.ErrNotMultipart) {
+			return nil, NewHTTPError(http.StatusUnsupportedMediaType, "expected a multipart/form-data body").
+				WithCode("unsupported_media_type").Wrap(err)
+		}
+		return nil, uploadError(err)
+	}
+	limits := wc.uploadLimitsFor()
+	values := make(url.Values)
+	var files []*UploadedFile
+	var total, valueBytes int64
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, uploadError(err)
+		}
+		name := part.FormName()
+		if part.FileName() == "" {
+			b, err := io.ReadAll(io.LimitReader(part, maxFormValueBytes-valueBytes+1))
+			if err != nil {
+				return nil, uploadError(err)
+			}
+			valueBytes += int64(len(b))
+			if valueBytes > maxFormValueBytes {
+				return nil, NewHTTPError(http.StatusRequestEntityTooLarge,
+					fmt.Sprintf("form values larger than %d bytes", maxFormValueBytes)).WithCode("body_too_large")
+			}
+			values.Add(name, string(b))
+			continue
+		}
+		if limits.MaxFiles > 0 && len(files) >= limits.MaxFiles {
+			return nil, NewHTTPError(http.StatusRequestEntityTooLarge, fmt.Sprintf("more than %d files", limits.MaxFiles)).
+				WithCode("too_many_files")
+		}
+		f := &UploadedFile{Field: name, Filename: part.FileName(), Header: part.Header}
+		sniff := make([]byte, sniffLen)
+		n, err := io.ReadFull(part, sniff)
+		if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+			return nil, uploadError(err)
+		}
+		sniff = sniff[:n]
+		f.ContentType, _, _ = mime.ParseMediaType(http.DetectContentType(sniff))
+		if !typeAllowed(f.ContentType, limits.AllowedTypes) {
+			return nil, NewHTTPError(http.StatusUnsupportedMediaType,
+				fmt.Sprintf("file %q of type %s is not allowed", f.Filename, f.ContentType)).WithCode("file_type_not_allowed")
+		}
+		w, err := dst(f)
+		if err != nil {
+			return nil, err
+		}
+		f.Size, err = copyUpload(w, io.MultiReader(bytes.NewReader(sniff), part), f.Filename, limits, total)
+		if err != nil {
+			return nil, err
+		}
+		total += f.Size
+		files = append(files, f)
+	}
+	//the form values can still be read by Bind, ParseBody and Request.FormValue
+	r.MultipartForm = &multipart.Form{Value: values}
+	r.PostForm = values
+	for k, v := range values {
+		r.Form[k] = append(r.Form[k], v...)
+	}
+	wc.uploads = files
+	wc.uploadsRead = true
+	return files, nil
+}
+
+// copyUpload ... copies a file to w, a 413 if it is larger than the limits allow
+func copyUpload(w io.Writer, src io.Reader, filename string, limits UploadLimits, total int64) (int64, error) {
+	limit := int64(-1)
+	var tooLarge *HTTPError
+	if limits.MaxFileSize > 0 {
+		limit = limits.MaxFileSize
+		tooLarge = NewHTTPError(http.StatusRequestEntityTooLarge,
+			fmt.Sprintf("file %q larger than %d bytes", filename, limits.MaxFileSize)).WithCode("file_too_large")
+	}
+	if limits.MaxTotalSize > 0 && (limit < 0 || limits.MaxTotalSize-total < limit) {
+		limit = limits.MaxTotalSize - total
+		tooLarge = NewHTTPError(http.StatusRequestEntityTooLarge,
+			fmt.Sprintf("files larger than %d bytes", limits.MaxTotalSize)).WithCode("upload_too_large")
+	}
+	if limit < 0 {
+		n, err := io.Copy(w, src)
+		if err != nil {
+			return n, uploadError(err)
+		}
+		return n, nil
+	}
+	n, err := io.Copy(w, io.LimitReader(src, limit))
+	if err != nil {
+		return n, uploadError(err)
+	}
+	if n == limit {
+		var one [1]byte
+		//one more byte tells if the file was larger than the limit
+		if more, _ := io.ReadFull(src, one[:]); more > 0 {
+			return n, tooLarge
+		}
+	}
+	return n, nil
+}
+
+// uploadError ... a 413 for a body over http.MaxBytesReader, a 400 for any other read error
+func uploadError(err error) error {
+	var maxErr *http.MaxBytesError
+	if errors.As(err, &maxErr) {
+		return NewHTTPError(http.StatusRequestEntityTooLarge, fmt.Sprintf("body larger than %d bytes", maxErr.Limit)).
+			WithCode("body_too_large").Wrap(err)
+	}
+	return NewHTTPError(http.StatusBadRequest, "invalid multipart body").WithCode("invalid_multipart").Wrap(err)
+}
+
+// typeAllowed ... true if the media type matches one of the allowed types, image/* matches every image
+func typeAllowed(mediaType string, allowed []string) bool {
+	if len(allowed) == 0 {
+		return true
+	}
+	for _, a := range allowed {
+		if a == mediaType || a == "*/*" {
+			return true
+		}
+		if prefix, ok := strings.CutSuffix(a, "/*"); ok && strings.HasPrefix(mediaType, prefix+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// FormFile ... the first file uploaded in the form field, a 400 HTTPError if there is none
+// the file is in a temp file removed at the end of the request
+func (wc *WebContext) FormFile(name string) (*UploadedFile, error) {
+	files, err := wc.MultipartFiles()
+	if err != nil {
+		return nil, err
+	}
+	for _, f := range files {
+		if f.Field == name {
+			return f, nil
+		}
+	}
+	return nil, NewHTTPError(http.StatusBadRequest, fmt.Sprintf("missing file %q", name)).WithCode("missing_file")
+}
+
+// SaveUploadedFile ... copies an uploaded file to dst, dst is replaced if it exists
+func (wc *WebContext) SaveUploadedFile(f *UploadedFile, dst string) error {
+	src, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, src); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
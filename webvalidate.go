@@ -0,0 +1,483 @@
+package gweb
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/mail"
+	"net/url"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// ValidationRule ... a custom rule for the validate tag, value is the field and param the text after = in the tag
+// return an error with the message for the client when the value is not valid
+type ValidationRule func(value any, param string) error
+
+// Validator ... implemented by a struct with rules across its fields
+// Validate is called after the tags of the struct are checked, return a FieldError to name the field
+type Validator interface {
+	Validate() error
+}
+
+// builtinRule ... returns the message for the client when v is not valid, err when the rule can not apply to v
+type builtinRule func(v reflect.Value, param string) (message string, err error)
+
+var builtinRules map[string]builtinRule
+
+func init() {
+	builtinRules = map[string]builtinRule{
+		"min":   ruleMin,
+		"max":   ruleMax,
+		"len":   ruleLen,
+		"oneof": ruleOneOf,
+		"regex": ruleRegex,
+		"email": ruleEmail,
+		"url":   ruleURL,
+		"uuid":  ruleUUID,
+	}
+}
+
+// the compiled regex rules
+var regexCache sync.Map
+
+// RegisterValidation ... adds a rule used by WebContext.Validate, it replaces a builtin rule of the same name
+func (w *Web) RegisterValidation(name string, rule ValidationRule) error {
+	if name == "" || strings.ContainsAny(name, ",= ") || rule == nil {
+		return fmt.Errorf("%s: %q", InvalidValidation, name)
+	}
+	if w.validations == nil {
+		w.validations = make(map[string]ValidationRule)
+	}
+	w.validations[name] = rule
+	return nil
+}
+
+// Validate ... checks v against the validate tags of its fields with the builtin rules
+// see WebContext.Validate for the rules
+func Validate(v any) error {
+	return validate(v, nil)
+}
+
+// Validate ... checks v against the validate tags of its fields, for example validate:"required,min=3,max=20"
+// the rules are required, omitempty, min, max, len, oneof, regex, email, url, uuid, dive and the rules registered on the Web
+// min, max and len are the length of strings, slices and maps and the value of numbers, oneof takes values separated by spaces
+// dive applies the rules after it to every element of a slice or map, nested structs are always checked
+// nil pointers are optional unless required and omitempty skips the other rules for a zero value
+// a struct implementing Validator is checked by its Validate method as well
+// the failures are returned together in a 422 HTTPError with a FieldError for each field
+func (wc *WebContext) Validate(v any) error {
+	var rules map[string]ValidationRule
+	if wc.web != nil {
+		rules = wc.web.validations
+	}
+	return validate(v, rules)
+}
+
+// validate ... checks v with the builtin rules and the custom rules
+func validate(v any, rules map[string]ValidationRule) error {
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Pointer && !rv.IsNil() {
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return fmt.Errorf("%s: %T", InvalidValidateTarget, v)
+	}
+	vd := validation{rules: rules}
+	if err := vd.validateStruct(rv, ""); err != nil {
+		return err
+	}
+	if len(vd.errs) == 0 {
+		return nil
+	}
+	messages := make([]string, len(vd.errs))
+	for i, fe := range vd.errs {
+		messages[i] = fe.Error()
+	}
+	return NewHTTPError(http.StatusUnprocessableEntity, "validation failed, "+strings.Join(messages, "; ")).
+		WithCode("validation_failed").
+		WithDetails(vd.errs)
+}
+
+// validation ... the state of one call to Validate
+type validation struct {
+	rules map[string]ValidationRule
+	errs  []FieldError
+}
+
+// validateStruct ... checks the fields of a struct, an error is only returned for a tag that can not apply
+func (vd *validation) validateStruct(rv reflect.Value, prefix string) error {
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		field := rt.Field(i)
+		fv := rv.Field(i)
+		if field.Anonymous && field.Tag.Get("validate") == "" {
+			if fv = indirect(fv); fv.IsValid() && fv.Kind() == reflect.Struct {
+				if err := vd.validateStruct(fv, prefix); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+		if !field.IsExported() {
+			continue
+		}
+		tag := field.Tag.Get("validate")
+		if tag == "-" {
+			continue
+		}
+		if err := vd.validateValue(fv, prefix+fieldName(field), splitRules(tag)); err != nil {
+			return err
+		}
+	}
+	vd.hook(rv, prefix)
+	return nil
+}
+
+// validateValue ... checks one value against the rules, only the first failing rule is reported
+func (vd *validation) validateValue(v reflect.Value, path string, rules []string) error {
+	for i, rule := range rules {
+		name, param, _ := strings.Cut(rule, "=")
+		switch name {
+		case "":
+			continue
+		case "omitempty":
+			if isZero(v) {
+				return nil
+			}
+			continue
+		case "required":
+			if isZero(v) {
+				vd.errs = append(vd.errs, FieldError{Field: path, Rule: name, Message: "is required"})
+				return nil
+			}
+			continue
+		case "dive":
+			return vd.dive(v, path, rules[i+1:])
+		}
+		ev := indirect(v)
+		if !ev.IsValid() {
+			//a nil pointer is optional
+			return nil
+		}
+		message, err := vd.check(name, ev, param)
+		if err != nil {
+			return fmt.Errorf("%s: %s on %s: %w", InvalidValidation, rule, path, err)
+		}
+		if message != "" {
+			vd.errs = append(vd.errs, FieldError{Field: path, Rule: name, Param: param, Message: message})
+			return nil
+		}
+	}
+	return vd.nested(indirect(v), path)
+}
+
+// dive ... checks every element of a slice, array or map against the rules
+func (vd *validation) dive(v reflect.Value, path string, rules []string) error {
+	ev := indirect(v)
+	if !ev.IsValid() {
+		return nil
+	}
+	switch ev.Kind() {
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < ev.Len(); i++ {
+			if err := vd.validateValue(ev.Index(i), fmt.Sprintf("%s[%d]", path, i), rules); err != nil {
+				return err
+			}
+		}
+	case reflect.Map:
+		for _, key := range sortedKeys(ev) {
+			if err := vd.validateValue(ev.MapIndex(key), fmt.Sprintf("%s[%v]", path, key), rules); err != nil {
+				return err
+			}
+		}
+	default:
+		return fmt.Errorf("%s: dive on %s, it is not a slice or a map", InvalidValidation, path)
+	}
+	return nil
+}
+
+// nested ... checks a struct and the structs in a slice or map
+func (vd *validation) nested(v reflect.Value, path string) error {
+	if !v.IsValid() {
+		return nil
+	}
+	switch v.Kind() {
+	case reflect.Struct:
+		if v.Type() == timeType {
+			return nil
+		}
+		return vd.validateStruct(v, path+".")
+	case reflect.Slice, reflect.Array:
+		if !isStruct(v.Type().Elem()) {
+			return nil
+		}
+		for i := 0; i < v.Len(); i++ {
+			if err := vd.nested(indirect(v.Index(i)), fmt.Sprintf("%s[%d]", path, i)); err != nil {
+				return err
+			}
+		}
+	case reflect.Map:
+		if !isStruct(v.Type().Elem()) {
+			return nil
+		}
+		for _, key := range sortedKeys(v) {
+			if err := vd.nested(indirect(v.MapIndex(key)), fmt.Sprintf("%s[%v]", path, key)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// hook ... calls the Validate method of the struct
+func (vd *validation) hook(rv reflect.Value, prefix string) {
+	var validator Validator
+	if rv.CanAddr() && rv.Addr().CanInterface() {
+		validator, _ = rv.Addr().Interface().(Validator)
+	} else if rv.CanInterface() {
+		validator, _ = rv.Interface().(Validator)
+	}
+	if validator == nil {
+		return
+	}
+	err := validator.Validate()
+	if err == nil {
+		return
+	}
+	path := strings.TrimSuffix(prefix, ".")
+	var fe FieldError
+	if errors.As(err, &fe) {
+		if fe.Field != "" && prefix != "" {
+			fe.Field = prefix + fe.Field
+		} else if fe.Field == "" {
+			fe.Field = path
+		}
+	} else {
+		fe = FieldError{Field: path, Message: err.Error()}
+	}
+	if fe.Rule == "" {
+		fe.Rule = "validate"
+	}
+	vd.errs = append(vd.errs, fe)
+}
+
+// check ... runs a custom or builtin rule
+func (vd *validation) check(name string, v reflect.Value, param string) (string, error) {
+	if rule, ok := vd.rules[name]; ok {
+		if !v.CanInterface() {
+			return "", nil
+		}
+		if err := rule(v.Interface(), param); err != nil {
+			return err.Error(), nil
+		}
+		return "", nil
+	}
+	rule, ok := builtinRules[name]
+	if !ok {
+		return "", errors.New(UnknownValidation)
+	}
+	return rule(v, param)
+}
+
+func ruleMin(v reflect.Value, param string) (string, error) {
+	return compare(v, param, "at least", func(n, limit float64) bool { return n >= limit })
+}
+
+func ruleMax(v reflect.Value, param string) (string, error) {
+	return compare(v, param, "at most", func(n, limit float64) bool { return n <= limit })
+}
+
+func ruleLen(v reflect.Value, param string) (string, error) {
+	if _, ok := length(v); !ok {
+		return "", fmt.Errorf("len needs a string, slice or map, not %s", v.Type())
+	}
+	return compare(v, param, "exactly", func(n, limit float64) bool { return n == limit })
+}
+
+// compare ... compares the length of strings, slices and maps or the value of numbers to the param
+func compare(v reflect.Value, param string, what string, ok func(n, limit float64) bool) (string, error) {
+	limit, err := strconv.ParseFloat(param, 64)
+	if err != nil {
+		return "", err
+	}
+	if n, isLength := length(v); isLength {
+		if ok(float64(n), limit) {
+			return "", nil
+		}
+		unit := "items"
+		if v.Kind() == reflect.String {
+			unit = "characters"
+		}
+		return fmt.Sprintf("must have %s %s %s", what, param, unit), nil
+	}
+	var n float64
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n = float64(v.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n = float64(v.Uint())
+	case reflect.Float32, reflect.Float64:
+		n = v.Float()
+	default:
+		return "", fmt.Errorf("needs a number, string, slice or map, not %s", v.Type())
+	}
+	if ok(n, limit) {
+		return "", nil
+	}
+	return fmt.Sprintf("must be %s %s", what, param), nil
+}
+
+func ruleOneOf(v reflect.Value, param string) (string, error) {
+	options := strings.Fields(param)
+	s := fmt.Sprint(v.Interface())
+	for _, option := range options {
+		if s == option {
+			return "", nil
+		}
+	}
+	return "must be one of " + strings.Join(options, ", "), nil
+}
+
+func ruleRegex(v reflect.Value, param string) (string, error) {
+	if v.Kind() != reflect.String {
+		return "", fmt.Errorf("regex needs a string, not %s", v.Type())
+	}
+	re, ok := regexCache.Load(param)
+	if !ok {
+		compiled, err := regexp.Compile(param)
+		if err != nil {
+			return "", err
+		}
+		re, _ = regexCache.LoadOrStore(param, compiled)
+	}
+	if re.(*regexp.Regexp).MatchString(v.String()) {
+		return "", nil
+	}
+	return "must match " + param, nil
+}
+
+func ruleEmail(v reflect.Value, _ string) (string, error) {
+	if v.Kind() != reflect.String {
+		return "", fmt.Errorf("email needs a string, not %s", v.Type())
+	}
+	addr, err := mail.ParseAddress(v.String())
+	if err != nil || addr.Address != v.String() {
+		return "must be a valid email address", nil
+	}
+	return "", nil
+}
+
+func ruleURL(v reflect.Value, _ string) (string, error) {
+	if v.Kind() != reflect.String {
+		return "", fmt.Errorf("url needs a string, not %s", v.Type())
+	}
+	u, err := url.Parse(v.String())
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return "must be a valid URL", nil
+	}
+	return "", nil
+}
+
+func ruleUUID(v reflect.Value, _ string) (string, error) {
+	if v.Type() == reflect.TypeOf(UUID{}) {
+		return "", nil
+	}
+	if v.Kind() != reflect.String {
+		return "", fmt.Errorf("uuid needs a string, not %s", v.Type())
+	}
+	if _, err := ParseUUID(v.String()); err != nil {
+		return "must be a valid UUID", nil
+	}
+	return "", nil
+}
+
+// length ... the length of a string in characters or the number of items of a slice or map
+func length(v reflect.Value) (int, bool) {
+	switch v.Kind() {
+	case reflect.String:
+		return utf8.RuneCountInString(v.String()), true
+	case reflect.Slice, reflect.Array, reflect.Map:
+		return v.Len(), true
+	}
+	return 0, false
+}
+
+// isZero ... true for nil, empty and zero values
+func isZero(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		return v.IsNil()
+	case reflect.Slice, reflect.Map:
+		return v.Len() == 0
+	}
+	return v.IsZero()
+}
+
+// indirect ... follows pointers and interfaces, the zero Value for nil
+func indirect(v reflect.Value) reflect.Value {
+	for v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return reflect.Value{}
+		}
+		v = v.Elem()
+	}
+	return v
+}
+
+// isStruct ... true for a struct or a pointer to a struct other than time.Time
+func isStruct(t reflect.Type) bool {
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	return t.Kind() == reflect.Struct && t != timeType
+}
+
+// sortedKeys ... the keys of a map in a stable order for the errors
+func sortedKeys(v reflect.Value) []reflect.Value {
+	keys := v.MapKeys()
+	sort.Slice(keys, func(i, j int) bool {
+		return fmt.Sprint(keys[i]) < fmt.Sprint(keys[j])
+	})
+	return keys
+}
+
+// fieldName ... the name of a field in the errors, the json or bind tag if it has one
+func fieldName(field reflect.StructField) string {
+	if tag, ok := field.Tag.Lookup("json"); ok && tag != "-" {
+		return jsonName(field, tag)
+	}
+	for _, source := range bindSources {
+		if name := field.Tag.Get(source); name != "" && name != "-" {
+			return name
+		}
+	}
+	return field.Name
+}
+
+// splitRules ... splits the validate tag on commas, a comma in a regex is written as \,
+func splitRules(tag string) []string {
+	if tag == "" {
+		return nil
+	}
+	var rules []string
+	var rule strings.Builder
+	for i := 0; i < len(tag); i++ {
+		switch {
+		case tag[i] == '\\' && i+1 < len(tag) && tag[i+1] == ',':
+			rule.WriteByte(',')
+			i++
+		case tag[i] == ',':
+			rules = append(rules, rule.String())
+			rule.Reset()
+		default:
+			rule.WriteByte(tag[i])
+		}
+	}
+	return append(rules, rule.String())
+}
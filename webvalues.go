@@ -0,0 +1,64 @@
+package gweb
+
+import (
+	"context"
+	"net/http"
+)
+
+// contextKey ... the string keys of Set, so they can not collide with the keys of other packages
+type contextKey string
+
+// valueKey ... a string key is wrapped in contextKey, any other key is used as it is
+func valueKey(key any) any {
+	if s, ok := key.(string); ok {
+		return contextKey(s)
+	}
+	return key
+}
+
+// Context ... the context of the request, it has the values added with Set
+func (wc *WebContext) Context() context.Context {
+	return wc.Request.Context()
+}
+
+// WithContext ... replaces the context of the request for the rest of the chain
+// the handlers and http.Handlers after this one get the new context
+func (wc *WebContext) WithContext(ctx context.Context) {
+	if ctx == nil {
+		return
+	}
+	wc.Request = wc.Request.WithContext(ctx)
+}
+
+// Set ... stores a value for the rest of the request, for example the user found by an auth middleware
+// the value is added to the request context so http.Handlers and library calls given Context() see it too
+// the key must be comparable, a string key only matches Get and Value of this package
+func (wc *WebContext) Set(key any, value any) {
+	wc.WithContext(context.WithValue(wc.Context(), valueKey(key), value))
+}
+
+// Get ... the value stored with Set, false if there is none
+func (wc *WebContext) Get(key any) (any, bool) {
+	value := wc.Context().Value(valueKey(key))
+	return value, value != nil
+}
+
+// Value ... the value stored with Set as a T, false if there is none or it is another type
+func Value[T any](wc *WebContext, key any) (T, bool) {
+	value, ok := wc.Get(key)
+	if !ok {
+		var zero T
+		return zero, false
+	}
+	t, ok := value.(T)
+	return t, ok
+}
+
+// WrapHandler ... runs a standard http.Handler as a handler, it gets the response writer and
+// the request of the context with the values added with Set
+func WrapHandler(h http.Handler) WebHandler {
+	return func(wc *WebContext) error {
+		h.ServeHTTP(wc.Writer, wc.Request)
+		return nil
+	}
+}
@@ -0,0 +1,333 @@
+package gweb
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+)
+
+// ResponseWriter ... the http.ResponseWriter of a WebContext
+// it sends the status set with WebContext.Status on the first write and keeps track of what was sent
+type ResponseWriter interface {
+	http.ResponseWriter
+	http.Flusher
+	// SetStatus ... the status sent on the first write if WriteHeader was not called
+	SetStatus(code int)
+	// StatusCode ... the status sent, or the one that will be sent if nothing was written yet
+	StatusCode() int
+	// Written ... true once the status line has been sent
+	Written() bool
+	// BytesWritten ... number of body bytes written
+	BytesWritten() int64
+	// Unwrap ... the wrapped writer, used by http.ResponseController
+	Unwrap() http.ResponseWriter
+}
+
+// responseWriter ... wraps the http.ResponseWriter of a request
+type responseWriter struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+	bytes       int64
+	//the body is counted but not sent, used for HEAD requests
+	discardBody bool
+}
+
+func newResponseWriter(wr http.ResponseWriter) *responseWriter {
+	return &responseWriter{ResponseWriter: wr}
+}
+
+func (rw *responseWriter) SetStatus(code int) {
+	if !rw.wroteHeader {
+		rw.status = code
+	}
+}
+
+func (rw *responseWriter) StatusCode() int {
+	if rw.status == 0 {
+		return http.StatusOK
+	}
+	return rw.status
+}
+
+func (rw *responseWriter) Written() bool {
+	return rw.wroteHeader
+}
+
+func (rw *responseWriter) BytesWritten() int64 {
+	return rw.bytes
+}
+
+func (rw *responseWriter) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
+
+// WriteHeader ... sends the status once, later calls are ignored
+// informational 1xx statuses are passed through
+func (rw *responseWriter) WriteHeader(code int) {
+	if rw.wroteHeader {
+		return
+	}
+	if code >= 100 && code < 200 && code != http.StatusSwitchingProtocols {
+		rw.ResponseWriter.WriteHeader(code)
+		return
+	}
+	rw.status = code
+	rw.wroteHeader = true
+	rw.ResponseWriter.WriteHeader(code)
+}
+
+func (rw *responseWriter) Write(b []byte) (int, error) {
+	if !rw.wroteHeader {
+		rw.WriteHeader(rw.StatusCode())
+	}
+	if rw.discardBody {
+		rw.bytes += int64(len(b))
+		return len(b), nil
+	}
+	n, err := rw.ResponseWriter.Write(b)
+	rw.bytes += int64(n)
+	return n, err
+}
+
+// ReadFrom ... keeps the sendfile optimisation of the wrapped writer for io.Copy
+func (rw *responseWriter) ReadFrom(r io.Reader) (int64, error) {
+	if !rw.wroteHeader {
+		rw.WriteHeader(rw.StatusCode())
+	}
+	var n int64
+	var err error
+	if rw.discardBody {
+		n, err = io.Copy(io.Discard, r)
+	} else if rf, ok := rw.ResponseWriter.(io.ReaderFrom); ok {
+		n, err = rf.ReadFrom(r)
+	} else {
+		n, err = io.Copy(rw.ResponseWriter, r)
+	}
+	rw.bytes += n
+	return n, err
+}
+
+// Flush ... sends the status if needed and flushes the buffered data to the client
+func (rw *responseWriter) Flush() {
+	if !rw.wroteHeader {
+		rw.WriteHeader(rw.StatusCode())
+	}
+	http.NewResponseController(rw.ResponseWriter).Flush()
+}
+
+// Hijack ... lets the caller take over the connection, for example for websockets
+func (rw *responseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn, buf, err := http.NewResponseController(rw.ResponseWriter).Hijack()
+	if err == nil {
+		rw.wroteHeader = true
+	}
+	return conn, buf, err
+}
+
+// DefaultBufferLimit ... the body size BufferResponse keeps in memory when no limit is given
+const DefaultBufferLimit = 1 << 20
+
+// BufferResponse ... a middleware that buffers the response of the rest of the chain in memory
+// until the chain returns the status, headers and body can still be replaced, so a failed handler
+// gets a clean error response and middlewares can compute an ETag or compress the body
+// a body larger than limit falls back to streaming, limit <= 0 uses DefaultBufferLimit
+func BufferResponse(limit int) WebHandler {
+	if limit <= 0 {
+		limit = DefaultBufferLimit
+	}
+	return func(wc *WebContext) error {
+		orig := wc.Writer
+		bw := newBufferedWriter(orig, limit)
+		wc.Writer = bw
+		err := wc.Next()
+		wc.Writer = orig
+		if err != nil && bw.buffering {
+			//drop the partial response, the error handler replies instead
+			return err
+		}
+		bw.commit()
+		return err
+	}
+}
+
+// BufferedWriter ... the ResponseWriter used by BufferResponse
+// handlers and middlewares inside the buffer can get it from WebContext.Writer
+type BufferedWriter struct {
+	orig   ResponseWriter
+	limit  int
+	header http.Header
+	//the headers before the handler ran, restored by Reset
+	snapshot    http.Header
+	buf         []byte
+	status      int
+	wroteHeader bool
+	//false once the body went over the limit or was flushed
+	buffering bool
+	streamed  int64
+}
+
+func newBufferedWriter(orig ResponseWriter, limit int) *BufferedWriter {
+	return &BufferedWriter{
+		orig:      orig,
+		limit:     limit,
+		header:    orig.Header().Clone(),
+		snapshot:  orig.Header().Clone(),
+		buffering: true,
+	}
+}
+
+func (bw *BufferedWriter) Header() http.Header {
+	if !bw.buffering {
+		return bw.orig.Header()
+	}
+	return bw.header
+}
+
+func (bw *BufferedWriter) SetStatus(code int) {
+	if !bw.wroteHeader {
+		bw.status = code
+	}
+	if !bw.buffering {
+		bw.orig.SetStatus(code)
+	}
+}
+
+func (bw *BufferedWriter) StatusCode() int {
+	if !bw.buffering {
+		return bw.orig.StatusCode()
+	}
+	if bw.status == 0 {
+		return http.StatusOK
+	}
+	return bw.status
+}
+
+// Written ... true once the response started streaming to the client, nothing is sent while buffering
+func (bw *BufferedWriter) Written() bool {
+	return !bw.buffering && bw.orig.Written()
+}
+
+func (bw *BufferedWriter) BytesWritten() int64 {
+	return int64(len(bw.buf)) + bw.streamed
+}
+
+func (bw *BufferedWriter) Unwrap() http.ResponseWriter {
+	return bw.orig
+}
+
+// Buffering ... true while the response is held in memory
+func (bw *BufferedWriter) Buffering() bool {
+	return bw.buffering
+}
+
+// Bytes ... the buffered body, nil once the response is streaming
+func (bw *BufferedWriter) Bytes() []byte {
+	if !bw.buffering {
+		return nil
+	}
+	return bw.buf
+}
+
+// SetBody ... replaces the buffered body, the status and headers are kept
+// returns false if the response is already streaming
+func (bw *BufferedWriter) SetBody(b []byte) bool {
+	if !bw.buffering {
+		return false
+	}
+	bw.buf = append(bw.buf[:0], b...)
+	return true
+}
+
+// Reset ... drops the buffered body and status and restores the headers from before the handler ran
+// returns false if the response is already streaming
+func (bw *BufferedWriter) Reset() bool {
+	if !bw.buffering {
+		return false
+	}
+	bw.buf = bw.buf[:0]
+	bw.status = 0
+	bw.wroteHeader = false
+	bw.header = bw.snapshot.Clone()
+	return true
+}
+
+func (bw *BufferedWriter) WriteHeader(code int) {
+	if !bw.buffering {
+		bw.orig.WriteHeader(code)
+		return
+	}
+	if bw.wroteHeader || code < 200 {
+		return
+	}
+	bw.status = code
+	bw.wroteHeader = true
+}
+
+func (bw *BufferedWriter) Write(b []byte) (int, error) {
+	if bw.buffering && len(bw.buf)+len(b) > bw.limit {
+		bw.stream()
+	}
+	if !bw.buffering {
+		n, err := bw.orig.Write(b)
+		bw.streamed += int64(n)
+		return n, err
+	}
+	bw.wroteHeader = true
+	bw.buf = append(bw.buf, b...)
+	return len(b), nil
+}
+
+// Flush ... the handler wants the data sent now, the response falls back to streaming
+func (bw *BufferedWriter) Flush() {
+	if bw.buffering {
+		bw.stream()
+	}
+	bw.orig.Flush()
+}
+
+// stream ... sends the headers and the buffered body and writes directly from now on
+func (bw *BufferedWriter) stream() {
+	bw.buffering = false
+	bw.copyHeader()
+	bw.orig.WriteHeader(bw.statusOrOK())
+	n, _ := bw.orig.Write(bw.buf)
+	bw.streamed += int64(n)
+	bw.buf = nil
+}
+
+// commit ... sends the buffered response with its Content-Length
+func (bw *BufferedWriter) commit() {
+	if !bw.buffering {
+		return
+	}
+	bw.buffering = false
+	bw.copyHeader()
+	if bw.orig.Header().Get("Content-Length") == "" && len(bw.buf) > 0 {
+		bw.orig.Header().Set("Content-Length", strconv.Itoa(len(bw.buf)))
+	}
+	bw.orig.WriteHeader(bw.statusOrOK())
+	n, _ := bw.orig.Write(bw.buf)
+	bw.streamed += int64(n)
+	bw.buf = nil
+}
+
+func (bw *BufferedWriter) statusOrOK() int {
+	if bw.status == 0 {
+		return http.StatusOK
+	}
+	return bw.status
+}
+
+// copyHeader ... replaces the headers of the wrapped writer with the buffered ones
+func (bw *BufferedWriter) copyHeader() {
+	dst := bw.orig.Header()
+	for k := range dst {
+		delete(dst, k)
+	}
+	for k, v := range bw.header {
+		dst[k] = v
+	}
+}